@@ -0,0 +1,18 @@
+package gserver
+
+import (
+	"fmt"
+	"io"
+)
+
+// WriteBundles writes bundles declared in config to outDir, for serving
+// static files without the server.
+func WriteBundles(cfg Config, w io.Writer, outDir string) error {
+	s := &setupStruct{Config: cfg}
+	mainStore := s.setupStore(nil)
+	written, err := mainStore.WriteBundles(outDir)
+	for _, name := range written {
+		fmt.Fprintln(w, name)
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package gserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+// Check loads content like Start and reports all problems to w, as text or
+// as a json array. It returns false if there is any error, warnings are
+// only reported. Invalid articles are errors even without LENIENT, valid
+// ones are still checked.
+func Check(cfg Config, w io.Writer, asJSON bool) bool {
+	s := &setupStruct{Config: cfg}
+	mainStore := s.setupStore(s.setupImages())
+	problems := mainStore.Check(cfg.Server.StaticDir)
+
+	ok := true
+	for _, p := range problems {
+		if !p.Warning {
+			ok = false
+		}
+	}
+
+	if mainStore.IsLoaded() {
+		reported := make(map[string]bool)
+		for _, p := range problems {
+			if p.Kind == "template" {
+				reported[p.File] = true
+			}
+		}
+		for _, p := range s.setupMainHandler(false, mainStore).Check() {
+			if !reported[p.File] {
+				problems = append(problems, p)
+				ok = false
+			}
+		}
+	}
+
+	if asJSON {
+		if problems == nil {
+			problems = []*store.Problem{}
+		}
+		data, _ := json.MarshalIndent(problems, "", "  ")
+		fmt.Fprintf(w, "%s\n", data)
+		return ok
+	}
+
+	errors, warnings := 0, 0
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+		if p.Warning {
+			warnings++
+		} else {
+			errors++
+		}
+	}
+	fmt.Fprintf(w, "%v errors, %v warnings\n", errors, warnings)
+	return ok
+}
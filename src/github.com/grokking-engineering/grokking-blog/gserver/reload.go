@@ -0,0 +1,142 @@
+package gserver
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/grokking-engineering/grokking-blog/utils/logs"
+)
+
+// reloadableKeys are config keys applied on SIGHUP. Changes of other keys
+// are logged and ignored until restart.
+var reloadableKeys = map[string]bool{
+	"BASE_URL":           true,
+	"SITE_TITLE":         true,
+	"SITE_DESCRIPTION":   true,
+	"SITE_DEFAULT_IMAGE": true,
+	"SITE_TWITTER":       true,
+	"SITEMAP_PRIORITY":   true,
+	"SITEMAP_CHANGEFREQ": true,
+	"dirs":               true,
+	"CACHE_PAGES":        true,
+	"CACHE_STATIC":       true,
+	"CACHE_FEEDS":        true,
+	"MINIFY_HTML":        true,
+	"LOG_LEVEL":          true,
+	"ADMIN_TOKEN":        true,
+	"ADMIN_USER":         true,
+	"ADMIN_PASSWORD":     true,
+	"RELOAD_PATH":        true,
+}
+
+// renderKeys change rendered pages, cached pages are removed when one of
+// them is reloaded.
+var renderKeys = map[string]bool{
+	"BASE_URL":           true,
+	"SITE_TITLE":         true,
+	"SITE_DESCRIPTION":   true,
+	"SITE_DEFAULT_IMAGE": true,
+	"SITE_TWITTER":       true,
+	"MINIFY_HTML":        true,
+}
+
+// secretKeys are not logged.
+var secretKeys = map[string]bool{
+	"ADMIN_TOKEN":    true,
+	"ADMIN_PASSWORD": true,
+}
+
+type configChange struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// walkConfig calls fn with each config field of old and new by json key,
+// like loadConfig does for environment variables.
+func walkConfig(old, new reflect.Value, fn func(key string, oldField, newField reflect.Value)) {
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("json")
+		if key == "" || key == "-" {
+			continue
+		}
+		if old.Field(i).Kind() == reflect.Struct {
+			walkConfig(old.Field(i), new.Field(i), fn)
+			continue
+		}
+		fn(key, old.Field(i), new.Field(i))
+	}
+}
+
+// diffConfig returns changed fields.
+func diffConfig(old, new Config) []configChange {
+	var changes []configChange
+	walkConfig(reflect.ValueOf(old), reflect.ValueOf(new), func(key string, oldField, newField reflect.Value) {
+		if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+			changes = append(changes, configChange{key, oldField.Interface(), newField.Interface()})
+		}
+	})
+	return changes
+}
+
+// mergeReloadable returns old with reloadable fields from new.
+func mergeReloadable(old, new Config) Config {
+	merged := old
+	walkConfig(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(new), func(key string, mergedField, newField reflect.Value) {
+		if reloadableKeys[key] {
+			mergedField.Set(newField)
+		}
+	})
+	return merged
+}
+
+// reload loads config with loadConfig and content changed since the last
+// reload, then serves with reloadable config fields. On error, old content
+// and config are kept.
+func (s *setupStruct) reload(loadConfig func() (Config, error)) error {
+	l.Println("Reloading content and config")
+	newCfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("Config: %v", err)
+	}
+	merged := mergeReloadable(s.Config, newCfg)
+	oldLevel := logs.Level()
+	if level := merged.Server.LogLevel; level != "" && level != s.Config.Server.LogLevel {
+		if err := logs.SetLevel(level); err != nil {
+			return fmt.Errorf("Config: %v", err)
+		}
+	}
+
+	err = s.mainStore.Reload()
+	if err != nil {
+		logs.SetLevel(oldLevel)
+		return err
+	}
+
+	stale := false
+	for _, change := range diffConfig(s.Config, newCfg) {
+		fields := logs.M{
+			"key": change.Key,
+			"old": change.Old,
+			"new": change.New,
+		}
+		if secretKeys[change.Key] {
+			fields["old"], fields["new"] = "***", "***"
+		}
+		if reloadableKeys[change.Key] {
+			stale = stale || renderKeys[change.Key]
+			l.WithFields(fields).Info("Config changed")
+		} else {
+			l.WithFields(fields).Error("Config change requires restart, ignored")
+		}
+	}
+	s.Config = merged
+	s.router.router.Store(s.newRouter())
+	if stale {
+		// pages rendered with the old config before the router was replaced
+		s.mainStore.Snapshot().Pages().Purge()
+	}
+	l.Println("Reloaded content and config")
+	return nil
+}
@@ -0,0 +1,162 @@
+package gserver
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grokking-engineering/grokking-blog/handlers"
+	"github.com/grokking-engineering/grokking-blog/utils/logs"
+)
+
+func TestDiffConfig(T *testing.T) {
+	var old, new Config
+	old.Site.Title = "Old"
+	new.Site.Title = "New"
+	new.Server.ListenAddr = ":9090"
+	new.Sitemap.Dirs = map[string]handlers.SitemapRule{}
+
+	changes := diffConfig(old, new)
+	keys := make([]string, len(changes))
+	for i, change := range changes {
+		keys[i] = change.Key
+	}
+	if strings.Join(keys, ",") != "LISTEN_ADDR,SITE_TITLE,dirs" {
+		T.Error("Unexpected changes", keys)
+	}
+
+	merged := mergeReloadable(old, new)
+	if merged.Site.Title != "New" || merged.Server.ListenAddr != "" {
+		T.Error("Expect only reloadable fields merged, got", merged.Site.Title, merged.Server.ListenAddr)
+	}
+}
+
+func TestReload(T *testing.T) {
+	contentDir, err := ioutil.TempDir("", "grokking-reload")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(contentDir)
+	files := map[string]string{
+		"_layout_main.tpl.html": `{{.SEO}}{{.Content}}`,
+		"_layout.tpl.html":      `{{.HtmlContent}}`,
+		"index.md":              "# Home\n\n> 01-03-2016\n\nHome\n",
+	}
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(contentDir, name), []byte(content), 0644)
+	}
+
+	var cfg Config
+	cfg.Server.ContentDir = contentDir
+	cfg.Server.StaticDir = contentDir
+	cfg.Server.ListenAddr = ":8080"
+	cfg.Site.Title = "Old"
+	s := setup(cfg)
+
+	title := func() string {
+		req, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		s.Handler.ServeHTTP(w, req)
+		for _, t := range []string{"Old", "New", "Newer"} {
+			if strings.Contains(w.Body.String(), `content="`+t+`"`) {
+				return t
+			}
+		}
+		return w.Body.String()
+	}
+	if t := title(); t != "Old" {
+		T.Fatal("Expect Old, got", t)
+	}
+
+	newCfg := cfg
+	newCfg.Site.Title = "New"
+	newCfg.Server.ListenAddr = ":9090"
+	err = s.reload(func() (Config, error) { return newCfg, nil })
+	if err != nil || title() != "New" || s.Config.Server.ListenAddr != ":8080" {
+		T.Error("Expect title reloaded and address kept, got", err, title(), s.Config.Server.ListenAddr)
+	}
+
+	// old state is kept on error
+	err = s.reload(func() (Config, error) { return Config{}, errors.New("invalid json") })
+	if err == nil || title() != "New" {
+		T.Error("Expect config error, got", err, title())
+	}
+	invalidLevel := newCfg
+	invalidLevel.Site.Title = "Newer"
+	invalidLevel.Server.LogLevel = "nope"
+	if err := s.reload(func() (Config, error) { return invalidLevel, nil }); err == nil || title() != "New" {
+		T.Error("Expect log level error, got", err, title())
+	}
+
+	ioutil.WriteFile(filepath.Join(contentDir, "_layout_main.tpl.html"), []byte(`{{.SEO`), 0644)
+	newerCfg := newCfg
+	newerCfg.Site.Title = "Newer"
+	newerCfg.Server.LogLevel = "debug"
+	if err := s.reload(func() (Config, error) { return newerCfg, nil }); err == nil || title() != "New" {
+		T.Error("Expect content error, got", err, title())
+	}
+	// LOG_LEVEL was not set, the level before reload is restored
+	if level := logs.Level(); level != "info" {
+		T.Error("Expect log level restored, got", level)
+	}
+}
+
+func TestReloadPageCache(T *testing.T) {
+	contentDir, err := ioutil.TempDir("", "grokking-reload")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(contentDir)
+	files := map[string]string{
+		"_layout_main.tpl.html": `{{.SEO}}{{.Content}}`,
+		"_layout.tpl.html":      `{{.HtmlContent}}`,
+		"index.md":              "# Home\n\n> 01-03-2016\n\nHome\n",
+	}
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(contentDir, name), []byte(content), 0644)
+	}
+
+	var cfg Config
+	cfg.Server.ContentDir = contentDir
+	cfg.Server.StaticDir = contentDir
+	cfg.Server.PageCacheMB = "1"
+	s := setup(cfg)
+
+	get := func() string {
+		req, _ := http.NewRequest("GET", "http://localhost/", nil)
+		w := httptest.NewRecorder()
+		s.Handler.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	cached := func() int {
+		n, _ := s.mainStore.Snapshot().Pages().Size()
+		return n
+	}
+	if get(); cached() != 0 {
+		T.Error("Expect cache bypassed without BASE_URL")
+	}
+
+	// BASE_URL set by reload enables the cache
+	newCfg := cfg
+	newCfg.Site.BaseURL = "https://grokking.org"
+	if err := s.reload(func() (Config, error) { return newCfg, nil }); err != nil {
+		T.Fatal(err)
+	}
+	if body := get(); cached() != 1 || !strings.Contains(body, `href="https://grokking.org/"`) {
+		T.Error("Expect page cached with BASE_URL, got", cached(), body)
+	}
+
+	newerCfg := newCfg
+	newerCfg.Site.BaseURL = "https://grokking.dev"
+	if err := s.reload(func() (Config, error) { return newerCfg, nil }); err != nil {
+		T.Fatal(err)
+	}
+	if body := get(); !strings.Contains(body, `href="https://grokking.dev/"`) {
+		T.Error("Expect page rendered with new BASE_URL, got", body)
+	}
+}
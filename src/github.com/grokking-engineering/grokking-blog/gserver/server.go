@@ -1,12 +1,20 @@
 package gserver
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/grokking-engineering/grokking-blog/handlers"
+	"github.com/grokking-engineering/grokking-blog/images"
 	"github.com/grokking-engineering/grokking-blog/middlewares"
 	"github.com/grokking-engineering/grokking-blog/store"
 	"github.com/grokking-engineering/grokking-blog/utils/logs"
@@ -20,87 +28,475 @@ type Config struct {
 		ContentDir    string `json:"CONTENT_DIR"`
 		StaticDir     string `json:"STATIC_DIR"`
 		IsDevelopment string `json:"DEVELOPMENT"`
+
+		// "1" to publish valid content when some articles are invalid
+		Lenient string `json:"LENIENT"`
+
+		// Maximum size of rendered pages kept in memory, "0" disables
+		PageCacheMB string `json:"PAGE_CACHE_MB"`
+
+		// Durations like "10s", empty or "0" for no timeout
+		ReadTimeout     string `json:"READ_TIMEOUT"`
+		WriteTimeout    string `json:"WRITE_TIMEOUT"`
+		IdleTimeout     string `json:"IDLE_TIMEOUT"`
+		ShutdownTimeout string `json:"SHUTDOWN_TIMEOUT"`
+		MaxHeaderBytes  string `json:"MAX_HEADER_BYTES"`
+
+		// Serve https and HTTP/2 when set, files are loaded again when they
+		// change
+		TLSCert string `json:"TLS_CERT"`
+		TLSKey  string `json:"TLS_KEY"`
+		// Optional http address redirecting to https, e.g. ":80"
+		RedirectAddr string `json:"REDIRECT_ADDR"`
+
+		// "debug", "info", "warning" or "error"
+		LogLevel string `json:"LOG_LEVEL"`
+
+		// "1" to remove comments and whitespace from pages and sitemap,
+		// ignored in development mode
+		MinifyHTML string `json:"MINIFY_HTML"`
+
+		// "1" to compress text responses with Brotli or gzip, and serve ".br"
+		// and ".gz" files in static dir in place of originals
+		Compress string `json:"COMPRESS"`
 	} `json:"server"`
+
+	Site struct {
+		BaseURL              string `json:"BASE_URL"`
+		Title                string `json:"SITE_TITLE"`
+		Description          string `json:"SITE_DESCRIPTION"`
+		DefaultImage         string `json:"SITE_DEFAULT_IMAGE"`
+		TwitterSite          string `json:"SITE_TWITTER"`
+		Languages            string `json:"LANGUAGES"`
+		RelatedTagWeight     string `json:"RELATED_TAG_WEIGHT"`
+		RelatedContentWeight string `json:"RELATED_CONTENT_WEIGHT"`
+	} `json:"site"`
+
+	Sitemap struct {
+		Priority   string                          `json:"SITEMAP_PRIORITY"`
+		ChangeFreq string                          `json:"SITEMAP_CHANGEFREQ"`
+		Dirs       map[string]handlers.SitemapRule `json:"dirs"`
+	} `json:"sitemap"`
+
+	// Cache-Control values, empty sends no header
+	Cache struct {
+		Pages  string `json:"CACHE_PAGES"`
+		Static string `json:"CACHE_STATIC"`
+		// sitemap.xml and robots.txt
+		Feeds string `json:"CACHE_FEEDS"`
+	} `json:"cache"`
+
+	// Admin API under "/__admin__/", disabled without credentials
+	Admin struct {
+		Token    string `json:"ADMIN_TOKEN"`
+		User     string `json:"ADMIN_USER"`
+		Password string `json:"ADMIN_PASSWORD"`
+
+		// Unprotected reload route, e.g. "/__reload__", empty disables it
+		ReloadPath string `json:"RELOAD_PATH"`
+	} `json:"admin"`
+
+	// Concatenated and minified files, by name in static dir:
+	//   "site.css": ["css/base.css", "css/article.css"]
+	Bundles map[string][]string `json:"bundles"`
+
+	Images struct {
+		Widths   string `json:"IMAGE_WIDTHS"`
+		CacheDir string `json:"IMAGE_CACHE_DIR"`
+		CWebP    string `json:"IMAGE_CWEBP"`
+	} `json:"images"`
 }
 
-func Start(cfg Config) error {
+// Start serves until SIGINT or SIGTERM, then waits for requests in progress
+// up to SHUTDOWN_TIMEOUT. It returns nil after a graceful stop. On SIGHUP,
+// content is reloaded and config is loaded again by loadConfig, see reload.
+func Start(cfg Config, loadConfig func() (Config, error)) error {
 	s := setup(cfg)
-	listenAddr := cfg.Server.ListenAddr
+	server := s.newServer()
+	s.setupTLS(server)
+	servers := []*http.Server{server}
+	if redirect := s.newRedirectServer(server); redirect != nil {
+		servers = append(servers, redirect)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
 
-	l.WithFields(logs.M{
-		"addr": listenAddr,
-	}).Info("Server is listening")
-	return http.ListenAndServe(listenAddr, s.Handler)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-hup:
+				err := s.reload(loadConfig)
+				if err != nil {
+					l.WithError(err).Error("Could not reload, keep old content and config")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for _, server := range servers {
+		l.WithFields(logs.M{
+			"addr": server.Addr,
+			"tls":  server.TLSConfig != nil,
+		}).Info("Server is listening")
+	}
+	return serve(servers, stop, parseDuration(cfg.Server.ShutdownTimeout, "SHUTDOWN_TIMEOUT"))
+}
+
+// serve runs servers until a signal is received from stop. Servers with
+// TLSConfig serve https.
+func serve(servers []*http.Server, stop <-chan os.Signal, shutdownTimeout time.Duration) error {
+	errc := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			if server.TLSConfig != nil {
+				errc <- server.ListenAndServeTLS("", "")
+			} else {
+				errc <- server.ListenAndServe()
+			}
+		}(server)
+	}
+
+	select {
+	case err := <-errc:
+		for _, server := range servers {
+			server.Close()
+		}
+		return err
+	case sig := <-stop:
+		l.WithFields(logs.M{
+			"signal":  sig.String(),
+			"timeout": shutdownTimeout.String(),
+		}).Info("Shutting down, waiting for requests in progress")
+	}
+
+	ctx := context.Background()
+	if shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, shutdownTimeout)
+		defer cancel()
+	}
+	var shutdownErr error
+	for _, server := range servers {
+		err := server.Shutdown(ctx)
+		if err != nil {
+			// drop remaining connections
+			server.Close()
+			shutdownErr = fmt.Errorf("Shutdown: %v", err)
+		}
+	}
+	return shutdownErr
+}
+
+func (s *setupStruct) newServer() *http.Server {
+	maxHeaderBytes := 0
+	if value := s.Config.Server.MaxHeaderBytes; value != "" {
+		var err error
+		maxHeaderBytes, err = strconv.Atoi(value)
+		if err != nil {
+			l.WithError(err).Fatal("Invalid MAX_HEADER_BYTES")
+		}
+	}
+
+	return &http.Server{
+		Addr:           s.Config.Server.ListenAddr,
+		Handler:        s.Handler,
+		ReadTimeout:    parseDuration(s.Config.Server.ReadTimeout, "READ_TIMEOUT"),
+		WriteTimeout:   parseDuration(s.Config.Server.WriteTimeout, "WRITE_TIMEOUT"),
+		IdleTimeout:    parseDuration(s.Config.Server.IdleTimeout, "IDLE_TIMEOUT"),
+		MaxHeaderBytes: maxHeaderBytes,
+	}
+}
+
+// parseDuration parses a duration config value, empty is 0.
+func parseDuration(value, name string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		l.WithError(err).Fatal("Invalid " + name)
+	}
+	return d
 }
 
 type setupStruct struct {
 	Config  Config
 	Handler http.Handler
+
+	// created once, kept on reload
+	isDev          bool
+	mainStore      *store.Instance
+	imageProcessor *images.Processor
+	router         *routerHolder
+}
+
+// routerHolder serves with the router of the last config reload.
+type routerHolder struct {
+	router atomic.Value // http.Handler
+}
+
+func (h *routerHolder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.router.Load().(http.Handler).ServeHTTP(w, req)
 }
 
 func setup(cfg Config) *setupStruct {
 	s := &setupStruct{Config: cfg}
+	s.setupLogs()
 	s.setupRoutes()
 
 	return s
 }
 
-func commonMiddlewares() func(http.Handler) http.Handler {
+func (s *setupStruct) setupLogs() {
+	if s.Config.Server.LogLevel == "" {
+		return
+	}
+	err := logs.SetLevel(s.Config.Server.LogLevel)
+	if err != nil {
+		l.WithError(err).Fatal("Invalid log level")
+	}
+}
+
+func commonMiddlewares(compress func(http.Handler) http.Handler) func(http.Handler) http.Handler {
 	logger := middlewares.NewLogger()
 	recovery := middlewares.NewRecovery()
 
 	return func(h http.Handler) http.Handler {
-		return recovery(logger(h))
+		return recovery(logger(compress(h)))
 	}
 }
 
-func (s *setupStruct) setupRoutes() {
-	mainStore := &store.Instance{
+func (s *setupStruct) setupCompress() func(http.Handler) http.Handler {
+	if s.Config.Server.Compress != "1" {
+		return func(h http.Handler) http.Handler { return h }
+	}
+	return middlewares.NewCompress(middlewares.DefaultCompressMinSize)
+}
+
+func (s *setupStruct) setupImages() *images.Processor {
+	cfg := s.Config.Images
+	if cfg.CacheDir == "" {
+		l.Println("Image processing is disabled")
+		return nil
+	}
+
+	widths, err := images.ParseWidths(cfg.Widths)
+	if err != nil {
+		l.WithError(err).Fatal("Invalid image widths")
+	}
+
+	processor := &images.Processor{
 		ContentDir: s.Config.Server.ContentDir,
+		StaticDir:  s.Config.Server.StaticDir,
+		CacheDir:   cfg.CacheDir,
+		Widths:     widths,
+		CWebP:      cfg.CWebP,
 	}
-	mainStore.Init()
+	processor.Init()
+	return processor
+}
 
-	isDev := s.Config.Server.IsDevelopment == "1"
-	if isDev {
-		l.Println("Server is running in DEVELOPMENT MODE")
+func (s *setupStruct) setupRelated() *store.RelatedWeights {
+	weights := store.DefaultRelatedWeights
+	parse := func(value string, weight *float64) {
+		if value == "" {
+			return
+		}
+		var err error
+		*weight, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			l.WithError(err).Fatal("Invalid related weight")
+		}
 	}
+
+	parse(s.Config.Site.RelatedTagWeight, &weights.Tags)
+	parse(s.Config.Site.RelatedContentWeight, &weights.Content)
+	return &weights
+}
+
+// splitList splits a comma separated config value.
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// setupStore creates the content store without loading it.
+func (s *setupStruct) setupStore(imageProcessor *images.Processor) *store.Instance {
+	pageCacheMB := 0
+	if value := s.Config.Server.PageCacheMB; value != "" {
+		var err error
+		pageCacheMB, err = strconv.Atoi(value)
+		if err != nil {
+			l.WithError(err).Fatal("Invalid page cache size")
+		}
+	}
+
+	return &store.Instance{
+		ContentDir:    s.Config.Server.ContentDir,
+		Languages:     splitList(s.Config.Site.Languages),
+		Images:        imageProcessor,
+		StaticDir:     s.Config.Server.StaticDir,
+		Bundles:       s.Config.Bundles,
+		Related:       s.setupRelated(),
+		Lenient:       s.Config.Server.Lenient == "1",
+		PageCacheSize: pageCacheMB << 20,
+	}
+}
+
+func (s *setupStruct) setupMainHandler(isDev bool, mainStore *store.Instance) *handlers.MainHandler {
 	mainHandler := &handlers.MainHandler{
 		Store: mainStore,
 		IsDev: isDev,
+		Site: handlers.Site{
+			BaseURL:      s.Config.Site.BaseURL,
+			Title:        s.Config.Site.Title,
+			Description:  s.Config.Site.Description,
+			DefaultImage: s.Config.Site.DefaultImage,
+			TwitterSite:  s.Config.Site.TwitterSite,
+		},
+		CacheControl: s.Config.Cache.Pages,
+		MinifyHTML:   s.Config.Server.MinifyHTML == "1",
+	}
+	if mainStore.PageCacheSize > 0 && mainHandler.Site.BaseURL == "" && !isDev {
+		l.Println("Page cache is bypassed, it requires BASE_URL")
 	}
 	mainHandler.Init()
+	return mainHandler
+}
 
-	router := http.NewServeMux()
-	s.Handler = router
-	common := commonMiddlewares()
+func (s *setupStruct) setupRoutes() {
+	s.isDev = s.Config.Server.IsDevelopment == "1"
+	if s.isDev {
+		l.Println("Server is running in DEVELOPMENT MODE")
+	}
 
-	staticDir := s.Config.Server.StaticDir
-	_, err := os.Stat(staticDir)
+	_, err := os.Stat(s.Config.Server.StaticDir)
 	if err != nil {
 		l.WithError(err).Fatal("Static dir not found")
 	}
 
+	s.imageProcessor = s.setupImages()
+	s.mainStore = s.setupStore(s.imageProcessor)
+	s.mainStore.Init()
+
+	s.router = &routerHolder{}
+	s.router.router.Store(s.newRouter())
+	s.Handler = s.router
+}
+
+// newRouter creates handlers from config, with the store and image
+// processor of setupRoutes.
+func (s *setupStruct) newRouter() http.Handler {
+	isDev := s.isDev
+	mainStore := s.mainStore
+	imageProcessor := s.imageProcessor
+	staticDir := s.Config.Server.StaticDir
+	mainHandler := s.setupMainHandler(isDev, mainStore)
+
+	router := http.NewServeMux()
+	compress := s.setupCompress()
+	common := commonMiddlewares(compress)
+
 	router.Handle("/", common(mainHandler))
-	router.Handle("/static/", http.StripPrefix("/static/",
-		http.FileServer(http.Dir(staticDir))))
-	router.Handle("/__reload__", common(reloadHandler(mainStore)))
+	cacheStatic := middlewares.NewCacheControl(s.Config.Cache.Static)
+	fileServer := http.FileServer(http.Dir(staticDir))
+	if s.Config.Server.Compress == "1" {
+		fileServer = middlewares.NewPrecompressed(staticDir)(fileServer)
+	}
+	staticHandler := &handlers.StaticHandler{
+		Store: mainStore,
+		Files: fileServer,
+	}
+	staticHandler.Init()
+	router.Handle("/static/", cacheStatic(compress(http.StripPrefix("/static/",
+		staticHandler))))
+	sitemapHandler := &handlers.SitemapHandler{
+		Store:   mainStore,
+		BaseURL: s.Config.Site.BaseURL,
+		Default: handlers.SitemapRule{
+			Priority:   s.Config.Sitemap.Priority,
+			ChangeFreq: s.Config.Sitemap.ChangeFreq,
+		},
+		Dirs:   s.Config.Sitemap.Dirs,
+		Minify: s.Config.Server.MinifyHTML == "1" && !isDev,
+	}
+	sitemapHandler.Init()
+
+	cacheFeeds := middlewares.NewCacheControl(s.Config.Cache.Feeds)
+	router.Handle("/sitemap.xml", common(cacheFeeds(sitemapHandler)))
+	// the admin prefix is not listed, robots.txt is public
+	disallow := []string{"/__health__"}
+	if reloadPath := s.Config.Admin.ReloadPath; reloadPath != "" {
+		router.Handle(reloadPath, common(reloadHandler(mainStore)))
+		disallow = append(disallow, reloadPath)
+	}
+	router.Handle("/robots.txt", common(cacheFeeds(&handlers.RobotsHandler{
+		BaseURL:  s.Config.Site.BaseURL,
+		Disallow: disallow,
+	})))
+	adminHandler := &handlers.AdminHandler{
+		Store:    mainStore,
+		Token:    s.Config.Admin.Token,
+		User:     s.Config.Admin.User,
+		Password: s.Config.Admin.Password,
+	}
+	adminHandler.Init()
+	router.Handle(handlers.AdminPrefix, common(adminHandler))
+	healthHandler := &handlers.HealthHandler{Store: mainStore}
+	healthHandler.Init()
+	// without logger, health checks are frequent
+	router.Handle("/__health__", middlewares.NewRecovery()(healthHandler))
+	if imageProcessor != nil {
+		router.Handle(images.URLPrefix, common(http.StripPrefix(
+			strings.TrimSuffix(images.URLPrefix, "/"), imageProcessor)))
+	}
+	return router
 }
 
+// reloadHandler reloads content at most once every 5 seconds, without
+// authentication. Prefer "/__admin__/reload".
 func reloadHandler(mainStore *store.Instance) http.Handler {
+	var mutex sync.Mutex
 	lastReload := time.Now()
 
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
 		now := time.Now()
-		if now.Sub(lastReload) > 5*time.Second {
-			err := mainStore.ClearCacheAndReload()
+		allowed := now.Sub(lastReload) > 5*time.Second
+		if allowed {
+			lastReload = now
+		}
+		mutex.Unlock()
+
+		if allowed {
+			err := mainStore.Reload()
 			if err != nil {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 				w.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(w, "Could not reload, keep serving old content. Please check server logs!")
+				fmt.Fprintf(w, "Could not reload, keep serving old content.\n%v\n", err)
 				return
 			}
 
 			w.WriteHeader(http.StatusOK)
 			fmt.Fprintf(w, "Reloaded!")
+			if errs := mainStore.Errors(); len(errs) > 0 {
+				fmt.Fprintf(w, "\nSkipped invalid content:\n%v\n", errs)
+			}
 			return
 		}
 
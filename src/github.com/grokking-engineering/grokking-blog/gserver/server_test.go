@@ -0,0 +1,94 @@
+package gserver
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a local address to listen on.
+func freeAddr(T *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func waitListening(addr string) {
+	for i := 0; i < 100; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func startTestServer(T *testing.T, handler http.Handler, shutdownTimeout time.Duration) (string, chan os.Signal, chan error) {
+	addr := freeAddr(T)
+	stop := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	server := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		done <- serve([]*http.Server{server}, stop, shutdownTimeout)
+	}()
+	waitListening(addr)
+	return addr, stop, done
+}
+
+func TestGracefulShutdown(T *testing.T) {
+	started := make(chan bool)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	addr, stop, done := startTestServer(T, handler, 5*time.Second)
+
+	result := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		result <- string(body)
+	}()
+
+	<-started
+	stop <- syscall.SIGTERM
+	if body := <-result; body != "done" {
+		T.Error("Expect request in progress completed, got", body)
+	}
+	if err := <-done; err != nil {
+		T.Error("Expect graceful stop, got", err)
+	}
+	if _, err := http.Get("http://" + addr + "/"); err == nil {
+		T.Error("Expect server stopped")
+	}
+}
+
+func TestShutdownTimeout(T *testing.T) {
+	started := make(chan bool)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		time.Sleep(2 * time.Second)
+	})
+	addr, stop, done := startTestServer(T, handler, 50*time.Millisecond)
+
+	go http.Get("http://" + addr + "/")
+	<-started
+	stop <- syscall.SIGTERM
+	if err := <-done; err == nil {
+		T.Error("Expect error when requests are not completed in time")
+	}
+}
@@ -0,0 +1,151 @@
+package gserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grokking-engineering/grokking-blog/utils/logs"
+)
+
+// certReloader loads the certificate again when its files change, so
+// renewed certificates are used without restarting.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	// how often files are checked, on handshake
+	interval time.Duration
+
+	mutex     sync.Mutex
+	cert      *tls.Certificate
+	signature string
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: 10 * time.Second,
+	}
+	signature, err := c.fileSignature()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.load(signature); err != nil {
+		return nil, err
+	}
+	c.checkedAt = time.Now()
+	return c, nil
+}
+
+// fileSignature changes when either file is modified.
+func (c *certReloader) fileSignature() (string, error) {
+	sig := ""
+	for _, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		sig += fmt.Sprintf("%v %v;", info.ModTime().UnixNano(), info.Size())
+	}
+	return sig, nil
+}
+
+func (c *certReloader) load(signature string) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert = &cert
+	c.signature = signature
+	return nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate. When new files are
+// invalid, e.g. only one of them is written, the old certificate is kept
+// and they are loaded again on the next check.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if time.Since(c.checkedAt) >= c.interval {
+		c.checkedAt = time.Now()
+		signature, err := c.fileSignature()
+		if err == nil && signature != c.signature {
+			err = c.load(signature)
+			if err == nil {
+				l.WithFields(logs.M{
+					"cert": c.certFile,
+				}).Info("Reloaded certificate")
+			}
+		}
+		if err != nil {
+			l.WithError(err).Error("Unable to reload certificate, keep the old one")
+		}
+	}
+	return c.cert, nil
+}
+
+// setupTLS sets TLSConfig of server from TLS_CERT and TLS_KEY, and leaves
+// it nil when TLS is not configured.
+func (s *setupStruct) setupTLS(server *http.Server) {
+	cfg := s.Config.Server
+	if cfg.TLSCert == "" && cfg.TLSKey == "" {
+		return
+	}
+	if cfg.TLSCert == "" || cfg.TLSKey == "" {
+		l.Fatal("Both TLS_CERT and TLS_KEY are required")
+	}
+
+	reloader, err := newCertReloader(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		l.WithError(err).Fatal("Unable to load certificate")
+	}
+	server.TLSConfig = &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
+
+// newRedirectServer redirects http requests on REDIRECT_ADDR to the https
+// server, nil when not configured.
+func (s *setupStruct) newRedirectServer(httpsServer *http.Server) *http.Server {
+	addr := s.Config.Server.RedirectAddr
+	if addr == "" {
+		return nil
+	}
+	if httpsServer.TLSConfig == nil {
+		l.Fatal("REDIRECT_ADDR requires TLS_CERT and TLS_KEY")
+	}
+
+	return &http.Server{
+		Addr:         addr,
+		Handler:      redirectHandler(httpsServer.Addr),
+		ReadTimeout:  httpsServer.ReadTimeout,
+		WriteTimeout: httpsServer.WriteTimeout,
+		IdleTimeout:  httpsServer.IdleTimeout,
+	}
+}
+
+// redirectHandler redirects to the same url with https on the port of
+// httpsAddr.
+func redirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,174 @@
+package gserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var certWrites int
+
+// writeSelfSignedCert writes a certificate for 127.0.0.1 with commonName,
+// and returns its pem encoding.
+func writeSelfSignedCert(T *testing.T, certFile, keyFile, commonName string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		T.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		T.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		T.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	ioutil.WriteFile(certFile, certPEM, 0644)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	// modification time must change on fast file systems
+	certWrites++
+	later := time.Now().Add(time.Duration(certWrites) * time.Second)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	return certPEM
+}
+
+func commonName(T *testing.T, cert *tls.Certificate) string {
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		T.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestCertReloader(T *testing.T) {
+	dir, err := ioutil.TempDir("", "grokking-tls")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	writeSelfSignedCert(T, certFile, keyFile, "first")
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		T.Fatal(err)
+	}
+	reloader.interval = 0
+
+	cert, _ := reloader.GetCertificate(nil)
+	if name := commonName(T, cert); name != "first" {
+		T.Error("Expect first certificate, got", name)
+	}
+
+	writeSelfSignedCert(T, certFile, keyFile, "second")
+	cert, _ = reloader.GetCertificate(nil)
+	if name := commonName(T, cert); name != "second" {
+		T.Error("Expect reloaded certificate, got", name)
+	}
+
+	// a key not matching the certificate is not loaded
+	ioutil.WriteFile(keyFile, []byte("invalid"), 0600)
+	cert, err = reloader.GetCertificate(nil)
+	if err != nil || commonName(T, cert) != "second" {
+		T.Error("Expect old certificate kept, got", err)
+	}
+
+	if _, err := newCertReloader(filepath.Join(dir, "nope.pem"), keyFile); err == nil {
+		T.Error("Expect error for missing files")
+	}
+}
+
+func TestServeTLS(T *testing.T) {
+	dir, err := ioutil.TempDir("", "grokking-tls")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	certPEM := writeSelfSignedCert(T, certFile, keyFile, "localhost")
+
+	s := &setupStruct{}
+	s.Config.Server.TLSCert = certFile
+	s.Config.Server.TLSKey = keyFile
+	s.Config.Server.ListenAddr = freeAddr(T)
+	s.Config.Server.RedirectAddr = freeAddr(T)
+	s.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Proto))
+	})
+	server := s.newServer()
+	s.setupTLS(server)
+	redirect := s.newRedirectServer(server)
+
+	stop := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve([]*http.Server{server, redirect}, stop, time.Second)
+	}()
+	waitListening(server.Addr)
+	waitListening(redirect.Addr)
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	tlsConfig := &tls.Config{RootCAs: pool, ServerName: "localhost"}
+
+	conn, err := tls.Dial("tcp", server.Addr, &tls.Config{
+		RootCAs:    pool,
+		ServerName: "localhost",
+		NextProtos: []string{"h2"},
+	})
+	if err != nil {
+		T.Fatal(err)
+	}
+	if proto := conn.ConnectionState().NegotiatedProtocol; proto != "h2" {
+		T.Error("Expect HTTP/2 negotiated, got", proto)
+	}
+	conn.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	_, port, _ := net.SplitHostPort(server.Addr)
+	_, redirectPort, _ := net.SplitHostPort(redirect.Addr)
+	resp, err := client.Get("http://localhost:" + redirectPort + "/blog/?a=b")
+	if err != nil {
+		T.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "HTTP/1.1" {
+		T.Error("Expect served over TLS, got", string(body))
+	}
+	if url := resp.Request.URL.String(); url != "https://localhost:"+port+"/blog/?a=b" {
+		T.Error("Expect redirected to https, got", url)
+	}
+
+	stop <- os.Interrupt
+	if err := <-done; err != nil {
+		T.Error("Expect graceful stop, got", err)
+	}
+}
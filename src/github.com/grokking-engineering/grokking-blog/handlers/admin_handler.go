@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+// AdminPrefix is the path of the admin API.
+const AdminPrefix = "/__admin__/"
+
+// AdminHandler serves the admin API under AdminPrefix, protected by a bearer
+// token or basic auth. All responses are json:
+//
+//	POST /__admin__/reload   reload changed content, all content with "?full=1"
+//	GET  /__admin__/status   snapshot version, load time, entries and errors
+//	POST /__admin__/purge    remove rendered pages from cache
+//
+// All requests are rejected when no credentials are configured.
+type AdminHandler struct {
+	Store *store.Instance
+
+	// "Authorization: Bearer <Token>", disabled when empty
+	Token string
+	// Basic auth, disabled when empty
+	User     string
+	Password string
+}
+
+func (this *AdminHandler) Init() {
+	if this.Store == nil {
+		panic("Required object is nil")
+	}
+}
+
+type adminPages struct {
+	Count int `json:"count"`
+	Bytes int `json:"bytes"`
+}
+
+type adminError struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Phase   string `json:"phase"`
+	Message string `json:"message"`
+}
+
+func newAdminErrors(errs store.LoadErrors) []adminError {
+	list := []adminError{}
+	for _, err := range errs {
+		list = append(list, adminError{
+			File:    err.File,
+			Line:    err.Line,
+			Phase:   err.Phase,
+			Message: err.Err.Error(),
+		})
+	}
+	return list
+}
+
+type adminStatus struct {
+	Version  int          `json:"version"`
+	LoadedAt *time.Time   `json:"loadedAt,omitempty"`
+	Entries  int          `json:"entries"`
+	Pages    adminPages   `json:"pages"`
+	Errors   []adminError `json:"errors"`
+}
+
+type adminResult struct {
+	Error string `json:"error,omitempty"`
+	adminStatus
+}
+
+func (this *AdminHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !this.authorized(req) {
+		if this.User != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		}
+		writeJSON(w, http.StatusUnauthorized, adminResult{Error: "Unauthorized"})
+		return
+	}
+
+	method := "GET"
+	action := strings.TrimPrefix(req.URL.Path, AdminPrefix)
+	switch action {
+	case "reload", "purge":
+		method = "POST"
+	case "status":
+	default:
+		writeJSON(w, http.StatusNotFound, adminResult{Error: "Not found"})
+		return
+	}
+	if req.Method != method {
+		w.Header().Set("Allow", method)
+		writeJSON(w, http.StatusMethodNotAllowed, adminResult{Error: "Method not allowed"})
+		return
+	}
+
+	switch action {
+	case "reload":
+		var err error
+		if req.URL.Query().Get("full") == "1" {
+			err = this.Store.ClearCacheAndReload()
+		} else {
+			err = this.Store.Reload()
+		}
+		if err != nil {
+			// old content is still served
+			writeJSON(w, http.StatusInternalServerError, adminResult{
+				Error:       "Could not reload, keep serving old content",
+				adminStatus: this.status(),
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, adminResult{adminStatus: this.status()})
+
+	case "status":
+		writeJSON(w, http.StatusOK, adminResult{adminStatus: this.status()})
+
+	case "purge":
+		purged := 0
+		if snapshot := this.Store.Snapshot(); snapshot != nil {
+			purged = snapshot.Pages().Purge()
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"purged": purged})
+	}
+}
+
+// authorized compares credentials in constant time.
+func (this *AdminHandler) authorized(req *http.Request) bool {
+	equal := func(a, b string) bool {
+		return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+	}
+
+	auth := req.Header.Get("Authorization")
+	if this.Token != "" && strings.HasPrefix(auth, "Bearer ") {
+		return equal(strings.TrimPrefix(auth, "Bearer "), this.Token)
+	}
+	if this.User != "" && this.Password != "" {
+		user, password, ok := req.BasicAuth()
+		return ok && equal(user, this.User) && equal(password, this.Password)
+	}
+	return false
+}
+
+func (this *AdminHandler) status() adminStatus {
+	status := adminStatus{Errors: newAdminErrors(this.Store.Errors())}
+	snapshot := this.Store.Snapshot()
+	if snapshot == nil {
+		return status
+	}
+	loadedAt := snapshot.LoadedAt()
+	status.Version = snapshot.Version()
+	status.LoadedAt = &loadedAt
+	status.Entries = len(snapshot.GetEntries())
+	status.Pages.Count, status.Pages.Bytes = snapshot.Pages().Size()
+	return status
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func adminRequest(handler http.Handler, method, url string, auth func(*http.Request)) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, nil)
+	if auth != nil {
+		auth(req)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAuth(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 0, 1)
+	defer cleanup()
+
+	bearer := func(token string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }
+	}
+	basic := func(user, password string) func(*http.Request) {
+		return func(req *http.Request) { req.SetBasicAuth(user, password) }
+	}
+
+	disabled := &AdminHandler{Store: handler.Store}
+	disabled.Init()
+	for _, auth := range []func(*http.Request){nil, bearer(""), basic("", "")} {
+		if w := adminRequest(disabled, "GET", "/__admin__/status", auth); w.Code != http.StatusUnauthorized {
+			T.Error("Expect rejected without credentials configured, got", w.Code)
+		}
+	}
+
+	admin := &AdminHandler{Store: handler.Store, Token: "secret", User: "admin", Password: "pass"}
+	admin.Init()
+	tests := []struct {
+		auth func(*http.Request)
+		code int
+	}{
+		{nil, http.StatusUnauthorized},
+		{bearer("wrong"), http.StatusUnauthorized},
+		{basic("admin", "wrong"), http.StatusUnauthorized},
+		{bearer("secret"), http.StatusOK},
+		{basic("admin", "pass"), http.StatusOK},
+	}
+	for _, test := range tests {
+		w := adminRequest(admin, "GET", "/__admin__/status", test.auth)
+		if w.Code != test.code {
+			T.Error("Expect", test.code, "got", w.Code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			T.Error("Expect WWW-Authenticate")
+		}
+	}
+}
+
+func TestAdminActions(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 1<<20, 3)
+	defer cleanup()
+
+	admin := &AdminHandler{Store: handler.Store, Token: "secret"}
+	admin.Init()
+	auth := func(req *http.Request) { req.Header.Set("Authorization", "Bearer secret") }
+	status := func(w *httptest.ResponseRecorder) adminResult {
+		var result adminResult
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			T.Fatal("Expect json, got", w.Body.String())
+		}
+		return result
+	}
+
+	w := adminRequest(admin, "GET", "/__admin__/status", auth)
+	before := status(w)
+	if w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		T.Error("Expect json content type, got", w.Header().Get("Content-Type"))
+	}
+	if before.Version == 0 || before.LoadedAt == nil || before.Entries == 0 || before.Errors == nil {
+		T.Error("Expect status of loaded content, got", w.Body.String())
+	}
+
+	if w := adminRequest(admin, "GET", "/__admin__/reload", auth); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		T.Error("Expect reload to require POST, got", w.Code, w.Header())
+	}
+	w = adminRequest(admin, "POST", "/__admin__/reload?full=1", auth)
+	if after := status(w); w.Code != http.StatusOK || after.Version != before.Version+1 {
+		T.Error("Expect new version after reload, got", w.Code, w.Body.String())
+	}
+
+	get(handler, "/blog/post1")
+	get(handler, "/blog/post2")
+	w = adminRequest(admin, "POST", "/__admin__/purge", auth)
+	if w.Code != http.StatusOK || w.Body.String() != "{\"purged\":2}\n" {
+		T.Error("Expect pages purged, got", w.Code, w.Body.String())
+	}
+	if n, _ := handler.Store.Snapshot().Pages().Size(); n != 0 {
+		T.Error("Expect empty cache after purge, got", n)
+	}
+
+	if w := adminRequest(admin, "GET", "/__admin__/nope", auth); w.Code != http.StatusNotFound {
+		T.Error("Expect unknown action not found, got", w.Code)
+	}
+}
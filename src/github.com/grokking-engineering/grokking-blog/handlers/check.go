@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+// Check renders every entry with the main layout and reports pages which
+// can not be rendered.
+func (this *MainHandler) Check() (problems []*store.Problem) {
+	entries := this.Store.GetEntries()
+	var paths []string
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		entry := entries[path]
+		url := entryURL(path, entry)
+		err := this.checkRender(url)
+		if err != nil {
+			problems = append(problems, &store.Problem{
+				File:    entry.File,
+				Kind:    "render",
+				Message: fmt.Sprintf("Unable to render %v: %v", url, err),
+			})
+		}
+	}
+	return problems
+}
+
+func (this *MainHandler) checkRender(url string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	w := &checkWriter{header: make(http.Header)}
+	this.ServeHTTP(w, req)
+	// nothing written is a 200, like with net/http
+	if w.status != http.StatusOK && w.status != 0 {
+		return fmt.Errorf("status %v", w.status)
+	}
+	return nil
+}
+
+// checkWriter keeps the status of a rendered page and discards the body.
+type checkWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *checkWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *checkWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *checkWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(data), nil
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 0, 1)
+	defer cleanup()
+
+	if problems := handler.Check(); len(problems) != 0 {
+		T.Fatal("Expect all pages rendered, got", problems)
+	}
+
+	// the layout fails when executed for the article
+	ioutil.WriteFile(filepath.Join(handler.Store.ContentDir, "blog", "post0.tpl.html"), []byte(`{{.Nope}}`), 0644)
+	if err := handler.Store.Reload(); err != nil {
+		T.Fatal(err)
+	}
+	problems := handler.Check()
+	if len(problems) != 1 || problems[0].Kind != "render" || problems[0].File != "blog/post0.md" {
+		T.Error("Expect render problem, got", problems)
+	}
+}
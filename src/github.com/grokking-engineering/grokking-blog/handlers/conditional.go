@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+// computeETag returns a strong ETag of the rendered page.
+func computeETag(body []byte) string {
+	sum := sha1.Sum(body)
+	return `"` + hex.EncodeToString(sum[:10]) + `"`
+}
+
+// lastModified returns the latest article date or file modification time of
+// the entry, and of listed entries for directories.
+func lastModified(snapshot *store.Snapshot, entryPath string, entry *store.Entry) time.Time {
+	latest := func(e *store.Entry) time.Time {
+		t := e.ModTime
+		if e.Article != nil && e.Article.Date.After(t) {
+			t = e.Article.Date
+		}
+		return t
+	}
+
+	t := latest(entry)
+	if entry.IsDir {
+		if dir := snapshot.GetDir(entryPath); dir != nil {
+			for _, e := range dir.Entries {
+				if et := latest(e); et.After(t) {
+					t = et
+				}
+			}
+		}
+	}
+	return t
+}
+
+// checkNotModified sets validators and writes 304 when the client copy is
+// fresh. If-None-Match takes precedence over If-Modified-Since, because
+// Last-Modified does not change when only templates change.
+func checkNotModified(w http.ResponseWriter, req *http.Request, etag string, modTime time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return false
+	}
+
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err == nil && !modTime.Truncate(time.Second).After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
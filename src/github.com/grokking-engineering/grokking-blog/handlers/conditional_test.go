@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getWith(handler http.Handler, url string, header map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", url, nil)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestConditionalGet(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 1<<20, 3)
+	defer cleanup()
+	handler.CacheControl = "public, max-age=300"
+
+	first := get(handler, "/blog/post1")
+	etag := first.Header().Get("ETag")
+	lastModified := first.Header().Get("Last-Modified")
+	if first.Code != http.StatusOK || etag == "" || lastModified == "" {
+		T.Fatal("Expect validators, got", first.Code, first.Header())
+	}
+	if cc := first.Header().Get("Cache-Control"); cc != "public, max-age=300" {
+		T.Error("Expect Cache-Control, got", cc)
+	}
+
+	// uncached render has the same ETag
+	uncached, cleanup2 := newTestHandler(T, 0, 3)
+	defer cleanup2()
+	if tag := get(uncached, "/blog/post1").Header().Get("ETag"); tag != etag {
+		T.Error("Expect stable ETag, got", tag, etag)
+	}
+
+	for _, test := range []struct {
+		header map[string]string
+		code   int
+	}{
+		{map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": "Mon, 01 Jan 2001 00:00:00 GMT"}, http.StatusOK},
+		// If-None-Match takes precedence
+		{map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+	} {
+		w := getWith(handler, "/blog/post1", test.header)
+		if w.Code != test.code {
+			T.Errorf("%v: expect %v, got %v", test.header, test.code, w.Code)
+		}
+		if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+			T.Error("Expect empty body for 304")
+		}
+	}
+
+	handler.IsDev = true
+	if cc := get(handler, "/blog/post1").Header().Get("Cache-Control"); cc != "no-cache" {
+		T.Error("Expect no-cache in development mode, got", cc)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+var loadErrorsTemplate = template.Must(template.New("errors").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Unable to load content</title>
+<style>body{font-family:sans-serif;margin:2em}li{margin:.5em 0}code{color:#c00}</style>
+</head>
+<body>
+<h1>DEVELOPMENT MODE: Unable to load content</h1>
+<ul>
+{{range .}}<li><code>{{.File}}{{if .Line}}:{{.Line}}{{end}}</code> {{.Phase}}: {{.Err}}</li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// renderLoadErrors lists errors returned by Store.Reload, so they
+// can be fixed without reading server log.
+func renderLoadErrors(w http.ResponseWriter, err error) {
+	errs, ok := err.(store.LoadErrors)
+	if !ok {
+		errs = store.LoadErrors{{Phase: "load", Err: err}}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	must(loadErrorsTemplate.Execute(w, errs))
+}
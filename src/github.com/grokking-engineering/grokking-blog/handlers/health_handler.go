@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+// HealthHandler serves "/__health__" with the state of the last reload:
+//
+//	{"status": "degraded", "loadedAt": "...", "errors": 2}
+//
+// Status is "ok", "degraded" when some content is invalid, or "down" with
+// code 503 when no content is loaded. The endpoint is public, details of
+// errors are only listed by the admin API.
+type HealthHandler struct {
+	Store *store.Instance
+}
+
+func (this *HealthHandler) Init() {
+	if this.Store == nil {
+		panic("Required object is nil")
+	}
+}
+
+type healthStatus struct {
+	Status   string     `json:"status"`
+	LoadedAt *time.Time `json:"loadedAt,omitempty"`
+	Errors   int        `json:"errors"`
+}
+
+func (this *HealthHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	health := healthStatus{Status: "ok", Errors: len(this.Store.Errors())}
+	if health.Errors > 0 {
+		health.Status = "degraded"
+	}
+
+	code := http.StatusOK
+	if this.Store.IsLoaded() {
+		loadedAt := this.Store.LoadedAt()
+		health.LoadedAt = &loadedAt
+	} else {
+		health.Status = "down"
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(health)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+func TestHealth(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 0, 1)
+	defer cleanup()
+
+	health := &HealthHandler{Store: handler.Store}
+	health.Init()
+	status := func(health http.Handler, code int) map[string]interface{} {
+		w := get(health, "/__health__")
+		var result map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || w.Code != code {
+			T.Fatal("Expect json with", code, "got", w.Code, w.Body.String())
+		}
+		return result
+	}
+
+	if result := status(health, http.StatusOK); result["status"] != "ok" || result["loadedAt"] == nil || result["errors"] != 0.0 {
+		T.Error("Expect ok, got", result)
+	}
+
+	// errors are counted, details are left to the admin API
+	handler.Store.Lenient = true
+	ioutil.WriteFile(filepath.Join(handler.Store.ContentDir, "blog", "bad.md"), []byte(
+		"# Bad\n\n> 01-03-2016\n> series-part: x\n\nBad\n"), 0644)
+	handler.Store.Reload()
+	result := status(health, http.StatusOK)
+	if result["status"] != "degraded" || result["errors"] != 1.0 || len(result) != 3 {
+		T.Error("Expect degraded with error count only, got", result)
+	}
+	if w := get(health, "/__health__"); strings.Contains(w.Body.String(), "bad.md") {
+		T.Error("Expect no file names, got", w.Body.String())
+	}
+
+	down := &HealthHandler{Store: &store.Instance{ContentDir: handler.Store.ContentDir}}
+	down.Init()
+	if result := status(down, http.StatusServiceUnavailable); result["status"] != "down" {
+		T.Error("Expect down, got", result)
+	}
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"bytes"
-	"fmt"
 	"html/template"
 	"net/http"
 	"path/filepath"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/grokking-engineering/grokking-blog/store"
 	"github.com/grokking-engineering/grokking-blog/utils/logs"
+	"github.com/grokking-engineering/grokking-blog/utils/minify"
 )
 
 var l = logs.New("handlers")
@@ -17,6 +17,15 @@ var l = logs.New("handlers")
 type MainHandler struct {
 	Store *store.Instance
 	IsDev bool
+	Site  Site
+
+	// Cache-Control of pages and page bundle assets, e.g.
+	// "public, max-age=300". Always "no-cache" in development mode.
+	CacheControl string
+
+	// Remove comments and whitespace from rendered pages, except in <pre>.
+	// Disabled in development mode.
+	MinifyHTML bool
 }
 
 func (this *MainHandler) Init() {
@@ -27,26 +36,35 @@ func (this *MainHandler) Init() {
 
 func (this *MainHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if this.IsDev {
-		err := this.Store.ClearCacheAndReload()
+		err := this.Store.Reload()
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintln(w, "DEVELOPMENT MODE: Unable to reload content. Please check server log:", err)
+			renderLoadErrors(w, err)
 			return
 		}
 	}
 
+	// the whole request is served from one snapshot, even during reload
+	snapshot := this.Store.Snapshot()
+
 	entryPath, err := filepath.Rel("/", req.URL.Path)
 	if err != nil {
-		this.notFound(w)
+		this.notFound(w, req, snapshot)
 		return
 	}
 
 	l.WithFields(logs.M{
 		"entryPath": entryPath,
 	}).Info("Serve entry")
-	entry := this.Store.GetEntry(entryPath)
+	entry := snapshot.GetEntry(entryPath)
 	if entry == nil {
-		this.notFound(w)
+		// files next to articles, e.g. images in page bundles
+		if assetPath := snapshot.GetAsset(entryPath); assetPath != "" {
+			this.setCacheControl(w)
+			http.ServeFile(w, req, assetPath)
+			return
+		}
+
+		this.notFound(w, req, snapshot)
 		return
 	}
 
@@ -55,33 +73,89 @@ func (this *MainHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	this.renderEntry(w, entry)
+	this.renderEntry(w, req, snapshot, entryPath, entry)
 }
 
-func (this *MainHandler) notFound(w http.ResponseWriter) {
-	mainLayout := this.Store.GetMainLayout()
+func (this *MainHandler) errorPage(req *http.Request, snapshot *store.Snapshot, message string) *Page {
+	return &Page{
+		Title:   message,
+		Content: template.HTML(template.HTMLEscapeString(message)),
+		SEO:     this.renderSEO(req, snapshot, message, "", nil),
+	}
+}
+
+func (this *MainHandler) notFound(w http.ResponseWriter, req *http.Request, snapshot *store.Snapshot) {
+	mainLayout := snapshot.GetMainLayout(req.URL.Path)
 	w.WriteHeader(http.StatusNotFound)
-	must(mainLayout.Execute(w, "404 Not Found"))
+	must(mainLayout.Execute(w, this.errorPage(req, snapshot, "404 Not Found")))
 }
 
-func (this *MainHandler) serverError(w http.ResponseWriter) {
-	mainLayout := this.Store.GetMainLayout()
+func (this *MainHandler) serverError(w http.ResponseWriter, req *http.Request, snapshot *store.Snapshot) {
+	mainLayout := snapshot.GetMainLayout(req.URL.Path)
 	w.WriteHeader(http.StatusInternalServerError)
-	must(mainLayout.Execute(w, "500 Server Error"))
+	must(mainLayout.Execute(w, this.errorPage(req, snapshot, "500 Server Error")))
 }
 
-func (this *MainHandler) renderEntry(w http.ResponseWriter, entry *store.Entry) {
-	buf := &bytes.Buffer{}
+// renderEntry serves the page from snapshot cache, or renders and caches
+// it. The cache is bypassed in development mode, and when BaseURL is empty
+// because absolute urls in SEO tags then depend on the request host.
+func (this *MainHandler) renderEntry(w http.ResponseWriter, req *http.Request, snapshot *store.Snapshot, entryPath string, entry *store.Entry) {
+	key := entryURL(entryPath, entry)
+	useCache := !this.IsDev && this.Site.BaseURL != ""
+	if useCache {
+		if page := snapshot.Pages().Get(key); page != nil {
+			this.writePage(w, req, snapshot, entryPath, entry, page)
+			return
+		}
+	}
 
-	err := entry.Layout.Execute(buf, entry.Article)
+	buf := &bytes.Buffer{}
+	err := entry.Layout.Execute(buf, entry.TemplateData())
 	if err != nil {
 		l.WithError(err).Error("renderEntry")
-		this.serverError(w)
+		this.serverError(w, req, snapshot)
+		return
+	}
+
+	page := &Page{
+		Lang:    entry.Article.Lang,
+		Title:   entry.Article.Title,
+		Content: template.HTML(buf.String()),
+		SEO:     this.renderSEO(req, snapshot, entry.Article.Title, entryPath, entry),
+		Entry:   entry,
+	}
+	out := &bytes.Buffer{}
+	mainLayout := snapshot.GetMainLayout(entryPath)
+	must(mainLayout.Execute(out, page))
+
+	body := out.Bytes()
+	if this.MinifyHTML && !this.IsDev {
+		body = minify.HTML(body)
+	}
+	cached := &store.CachedPage{Body: body, ETag: computeETag(body)}
+	if useCache {
+		snapshot.Pages().Put(key, cached)
+	}
+	this.writePage(w, req, snapshot, entryPath, entry, cached)
+}
+
+// writePage writes a rendered page, or 304 when the client copy is fresh.
+func (this *MainHandler) writePage(w http.ResponseWriter, req *http.Request, snapshot *store.Snapshot, entryPath string, entry *store.Entry, page *store.CachedPage) {
+	this.setCacheControl(w)
+	if checkNotModified(w, req, page.ETag, lastModified(snapshot, entryPath, entry)) {
 		return
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page.Body)
+}
 
-	mainLayout := this.Store.GetMainLayout()
-	must(mainLayout.Execute(w, template.HTML(buf.String())))
+func (this *MainHandler) setCacheControl(w http.ResponseWriter) {
+	switch {
+	case this.IsDev:
+		w.Header().Set("Cache-Control", "no-cache")
+	case this.CacheControl != "":
+		w.Header().Set("Cache-Control", this.CacheControl)
+	}
 }
 
 func must(err error) {
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+var testFiles = map[string]string{
+	"_layout_main.tpl.html": `<html><head>{{.SEO}}</head><body>{{.Content}}</body></html>`,
+	"_layout.tpl.html":      `<h1>{{.Title}}</h1>{{.HtmlContent}}`,
+	"index.tpl.html":        `{{range dir "blog"}}<a href="/{{.Path}}">{{.Title}}</a>{{end}}`,
+	"index.md":              "# Home\n\n> 01-03-2016\n\nHome\n",
+	"blog/index.md":         "# Blog\n\n> 01-03-2016\n\nBlog\n",
+}
+
+func newTestHandler(T testing.TB, pageCacheSize int, articles int) (*MainHandler, func()) {
+	log.SetOutput(ioutil.Discard)
+	dir, err := ioutil.TempDir("", "grokking-handlers")
+	if err != nil {
+		T.Fatal(err)
+	}
+	files := make(map[string]string)
+	for name, content := range testFiles {
+		files[name] = content
+	}
+	for i := 0; i < articles; i++ {
+		files[fmt.Sprintf("blog/post%v.md", i)] = fmt.Sprintf(
+			"# Post %v\n\n> 01-03-2016 #go\n>\n> Short %v\n\n%v\n",
+			i, i, strings.Repeat("Some content of the post. ", 200))
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(content), 0644)
+	}
+
+	handler := &MainHandler{
+		Store: &store.Instance{ContentDir: dir, PageCacheSize: pageCacheSize},
+		Site:  Site{BaseURL: "https://grokking.org", Title: "Grokking"},
+	}
+	handler.Store.Init()
+	handler.Init()
+	return handler, func() {
+		os.RemoveAll(dir)
+		log.SetOutput(os.Stderr)
+	}
+}
+
+func get(handler http.Handler, url string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", url, nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestPageCache(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 1<<20, 3)
+	defer cleanup()
+
+	first := get(handler, "/blog/post1")
+	if first.Code != http.StatusOK || !strings.Contains(first.Body.String(), "<h1>Post 1</h1>") {
+		T.Fatal("Expect page rendered, got", first.Code, first.Body.String())
+	}
+	if n, _ := handler.Store.Snapshot().Pages().Size(); n != 1 {
+		T.Error("Expect page cached, got", n)
+	}
+
+	second := get(handler, "/blog/post1")
+	if second.Body.String() != first.Body.String() {
+		T.Error("Expect cached page served")
+	}
+
+	// not found pages are not cached
+	get(handler, "/blog/nope")
+	if n, _ := handler.Store.Snapshot().Pages().Size(); n != 1 {
+		T.Error("Expect only found pages cached, got", n)
+	}
+
+	// the key does not depend on the request host
+	get(handler, "http://evil.example/blog/post1")
+	if n, _ := handler.Store.Snapshot().Pages().Size(); n != 1 {
+		T.Error("Expect one page for all hosts, got", n)
+	}
+
+	handler.Store.Reload()
+	if n, _ := handler.Store.Snapshot().Pages().Size(); n != 0 {
+		T.Error("Expect empty cache after reload, got", n)
+	}
+
+	// without BaseURL, SEO tags use the request host
+	handler.Site.BaseURL = ""
+	get(handler, "/blog/post1")
+	if n, _ := handler.Store.Snapshot().Pages().Size(); n != 0 {
+		T.Error("Expect cache bypassed without base url, got", n)
+	}
+	handler.Site.BaseURL = "https://grokking.org"
+
+	handler.IsDev = true
+	get(handler, "/blog/post1")
+	if n, _ := handler.Store.Snapshot().Pages().Size(); n != 0 {
+		T.Error("Expect cache bypassed in development mode, got", n)
+	}
+}
+
+func TestPageCacheSize(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 10000, 3)
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		get(handler, fmt.Sprintf("/blog/post%v", i))
+	}
+	n, size := handler.Store.Snapshot().Pages().Size()
+	if n != 1 || size > 10000 {
+		T.Errorf("Expect cache bounded, got %v pages of %v bytes", n, size)
+	}
+}
+
+func benchmarkServe(B *testing.B, pageCacheSize int) {
+	handler, cleanup := newTestHandler(B, pageCacheSize, 100)
+	defer cleanup()
+
+	urls := []string{"/", "/blog/", "/blog/post1", "/blog/post50"}
+	B.ResetTimer()
+	B.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			w := get(handler, urls[i%len(urls)])
+			if w.Code != http.StatusOK {
+				B.Fatal("Unexpected status", w.Code)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkServeUncached(B *testing.B) {
+	benchmarkServe(B, 0)
+}
+
+func BenchmarkServeCached(B *testing.B) {
+	benchmarkServe(B, 64<<20)
+}
+
+func TestMinifyHTML(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 1<<20, 0)
+	defer cleanup()
+	ioutil.WriteFile(filepath.Join(handler.Store.ContentDir, "blog", "code.md"), []byte(
+		"# Code\n\n> 01-03-2016\n\n<div>\n    <pre>a   b\n    c</pre>\n</div>\n"), 0644)
+	handler.Store.Reload()
+	handler.MinifyHTML = true
+
+	body := get(handler, "/blog/code").Body.String()
+	if !strings.Contains(body, "<div>\n<pre>a   b\n    c</pre>\n</div>") {
+		T.Error("Expect whitespace collapsed except in <pre>, got", body)
+	}
+
+	handler.IsDev = true
+	if dev := get(handler, "/blog/code").Body.String(); !strings.Contains(dev, "<div>\n    <pre>") {
+		T.Error("Expect no minify in development mode, got", dev)
+	}
+}
+
+func TestPrivateFilesNotServed(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 0, 0)
+	defer cleanup()
+	for _, name := range []string{".git/config", "_drafts/x.png", "blog/photo.png"} {
+		path := filepath.Join(handler.Store.ContentDir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte("secret"), 0644)
+	}
+	handler.Store.Reload()
+
+	if w := get(handler, "/blog/photo.png"); w.Code != http.StatusOK {
+		T.Error("Expect asset served, got", w.Code)
+	}
+	for _, url := range []string{"/.git/config", "/_drafts/x.png"} {
+		if w := get(handler, url); w.Code != http.StatusNotFound {
+			T.Error("Expect not found", url, w.Code)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+// Site holds site-wide metadata for SEO and social tags.
+type Site struct {
+	// Absolute url like "https://grokking.org", use request host if empty.
+	BaseURL     string
+	Title       string
+	Description string
+
+	// Used when the article has no "image:" metadata.
+	DefaultImage string
+	// Twitter handle like "@grokking"
+	TwitterSite string
+}
+
+// Page is passed to _layout_main.tpl.html.
+type Page struct {
+	Title   string
+	Content template.HTML
+
+	// Language of the article, empty when Languages is not configured
+	Lang string
+
+	// <title>, description, canonical url, Open Graph, Twitter card and
+	// JSON-LD tags for <head>.
+	SEO template.HTML
+
+	// nil on error pages
+	Entry *store.Entry
+}
+
+type seoData struct {
+	Title       string
+	Description string
+	URL         string
+	Image       string
+	Type        string
+	SiteName    string
+	TwitterSite string
+	JSONLD      interface{}
+
+	Translations []seoTranslation
+}
+
+type seoTranslation struct {
+	Lang string
+	URL  string
+}
+
+var seoTemplate = template.Must(template.New("seo").Parse(`<title>{{.Title}}</title>
+{{with .Description}}<meta name="description" content="{{.}}">
+{{end}}{{with .URL}}<link rel="canonical" href="{{.}}">
+<meta property="og:url" content="{{.}}">
+{{end}}<meta property="og:type" content="{{.Type}}">
+<meta property="og:title" content="{{.Title}}">
+{{with .SiteName}}<meta property="og:site_name" content="{{.}}">
+{{end}}{{with .Description}}<meta property="og:description" content="{{.}}">
+{{end}}{{with .Image}}<meta property="og:image" content="{{.}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:image" content="{{.}}">
+{{else}}<meta name="twitter:card" content="summary">
+{{end}}{{with .TwitterSite}}<meta name="twitter:site" content="{{.}}">
+{{end}}<meta name="twitter:title" content="{{.Title}}">
+{{with .Description}}<meta name="twitter:description" content="{{.}}">
+{{end}}{{range .Translations}}<link rel="alternate" hreflang="{{.Lang}}" href="{{.URL}}">
+{{end}}{{with .JSONLD}}<script type="application/ld+json">{{.}}</script>
+{{end}}`))
+
+type jsonLDPerson struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type jsonLDBlogPosting struct {
+	Context       string         `json:"@context"`
+	Type          string         `json:"@type"`
+	Headline      string         `json:"headline"`
+	Description   string         `json:"description,omitempty"`
+	URL           string         `json:"url"`
+	Image         string         `json:"image,omitempty"`
+	DatePublished string         `json:"datePublished,omitempty"`
+	Keywords      string         `json:"keywords,omitempty"`
+	Author        []jsonLDPerson `json:"author,omitempty"`
+}
+
+// absoluteURL prefixes site relative urls with baseURL.
+func absoluteURL(baseURL, url string) string {
+	if url == "" || strings.Contains(url, "://") || strings.HasPrefix(url, "//") {
+		return url
+	}
+	url = strings.TrimPrefix(strings.TrimPrefix(url, "./"), "/")
+	return baseURL + "/" + url
+}
+
+func (this *MainHandler) renderSEO(req *http.Request, snapshot *store.Snapshot, title string, entryPath string, entry *store.Entry) template.HTML {
+	site := this.Site
+	base := baseURL(site.BaseURL, req)
+	data := seoData{
+		Title:       title,
+		Description: site.Description,
+		Type:        "website",
+		SiteName:    site.Title,
+		TwitterSite: site.TwitterSite,
+		Image:       absoluteURL(base, site.DefaultImage),
+	}
+	if site.Title != "" && title != site.Title {
+		data.Title = title + " | " + site.Title
+	}
+
+	if entry != nil {
+		article := entry.Article
+		data.URL = base + entryURL(entryPath, entry)
+		if article.Short != "" {
+			data.Description = article.Short
+		}
+		if article.Image != "" {
+			data.Image = absoluteURL(base, article.Image)
+		}
+		if len(article.Translations) > 0 {
+			// hreflang links must also reference the page itself
+			data.Translations = append(data.Translations, seoTranslation{article.Lang, data.URL})
+		}
+		for _, translation := range article.Translations {
+			data.Translations = append(data.Translations, seoTranslation{
+				Lang: translation.Lang,
+				URL:  base + "/" + string(translation.Path),
+			})
+		}
+
+		if !entry.IsDir || entry.IsBundle {
+			data.Type = "article"
+			posting := jsonLDBlogPosting{
+				Context:     "http://schema.org",
+				Type:        "BlogPosting",
+				Headline:    article.Title,
+				Description: article.Short,
+				URL:         data.URL,
+				Image:       data.Image,
+				Keywords:    strings.Join(article.Tags, ","),
+			}
+			if !article.Date.IsZero() {
+				posting.DatePublished = article.Date.Format("2006-01-02")
+			}
+			for _, id := range article.Authors {
+				if author := snapshot.GetAuthor(id); author != nil {
+					posting.Author = append(posting.Author, jsonLDPerson{"Person", author.Name})
+				}
+			}
+			data.JSONLD = posting
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := seoTemplate.Execute(buf, data)
+	if err != nil {
+		l.WithError(err).Error("renderSEO")
+		return ""
+	}
+	return template.HTML(buf.String())
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+var seoFiles = map[string]string{
+	"_layout_main.tpl.html": `{{.SEO}}`,
+	"_layout.tpl.html":      `{{.Title}}`,
+	"_authors/thanh.json":   `{"name": "Thanh Tran"}`,
+	"index.md":              "# Home\n\n> 01-03-2016\n\nHome\n",
+	"blog/index.md":         "# Blog\n\n> 01-03-2016\n\nBlog\n",
+	"blog/seo.md":           "# SEO & tags\n\n> 02-03-2016 #go #web\n> author: thanh\n> image: cover.png\n>\n> About \"SEO\"\n\nContent\n",
+}
+
+func TestSEO(T *testing.T) {
+	dir, err := ioutil.TempDir("", "grokking-seo")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range seoFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(content), 0644)
+	}
+
+	handler := &MainHandler{
+		Store: &store.Instance{ContentDir: dir},
+		Site: Site{
+			BaseURL:      "https://grokking.org",
+			Title:        "Grokking",
+			Description:  "Engineering blog",
+			DefaultImage: "/static/logo.png",
+			TwitterSite:  "@grokking",
+		},
+	}
+	handler.Store.Init()
+	handler.Init()
+	get := func(url string) string {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	body := get("/blog/seo")
+	expected := []string{
+		`<title>SEO &amp; tags | Grokking</title>`,
+		`<meta name="description" content="About &#34;SEO&#34;">`,
+		`<link rel="canonical" href="https://grokking.org/blog/seo">`,
+		`<meta property="og:type" content="article">`,
+		`<meta property="og:site_name" content="Grokking">`,
+		`<meta property="og:image" content="https://grokking.org/blog/cover.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`<meta name="twitter:site" content="@grokking">`,
+		`<script type="application/ld+json">{"@context":"http://schema.org","@type":"BlogPosting","headline":"SEO \u0026 tags","description":"About \"SEO\"","url":"https://grokking.org/blog/seo","image":"https://grokking.org/blog/cover.png","datePublished":"2016-03-02","keywords":"go,web","author":[{"@type":"Person","name":"Thanh Tran"}]}</script>`,
+	}
+	for _, tag := range expected {
+		if !strings.Contains(body, tag) {
+			T.Errorf("Expect %v, got %v", tag, body)
+		}
+	}
+
+	// sections use site description and default image
+	body = get("/blog/")
+	for _, tag := range []string{
+		`<meta name="description" content="Engineering blog">`,
+		`<meta property="og:type" content="website">`,
+		`<meta property="og:image" content="https://grokking.org/static/logo.png">`,
+	} {
+		if !strings.Contains(body, tag) {
+			T.Errorf("Expect %v, got %v", tag, body)
+		}
+	}
+	if strings.Contains(body, "application/ld+json") {
+		T.Error("Expect no JSON-LD for sections")
+	}
+
+	// error pages have no canonical url
+	body = get("/nope")
+	if !strings.Contains(body, "<title>404 Not Found | Grokking</title>") || strings.Contains(body, "canonical") {
+		T.Error("Expect error page tags, got", body)
+	}
+
+	// without BaseURL, urls use the request host
+	handler.Site.BaseURL = ""
+	if body := get("http://localhost:8080/blog/seo"); !strings.Contains(body, `<link rel="canonical" href="http://localhost:8080/blog/seo">`) {
+		T.Error("Expect request host, got", body)
+	}
+}
+
+func TestSEOTranslations(T *testing.T) {
+	dir, err := ioutil.TempDir("", "grokking-seo")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"_layout_main.tpl.html": `{{.SEO}}`,
+		"_layout.tpl.html":      `{{.Title}}`,
+		"index.md":              "# Trang chủ\n\n> 01-03-2016\n\nHome\n",
+		"index.en.md":           "# Home\n\n> 01-03-2016\n\nHome\n",
+		"blog/index.md":         "# Blog\n\n> 01-03-2016\n\nBlog\n",
+		"blog/post.md":          "# Bài viết\n\n> 01-03-2016\n\nVi\n",
+		"blog/post.en.md":       "# Post\n\n> 01-03-2016\n\nEn\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(content), 0644)
+	}
+
+	handler := &MainHandler{
+		Store: &store.Instance{ContentDir: dir, Languages: []string{"vi", "en"}},
+		Site:  Site{BaseURL: "https://grokking.org"},
+	}
+	handler.Store.Init()
+	handler.Init()
+
+	for _, test := range []struct {
+		url      string
+		expected []string
+	}{
+		{"/", []string{
+			`<link rel="alternate" hreflang="vi" href="https://grokking.org/">`,
+			`<link rel="alternate" hreflang="en" href="https://grokking.org/en/">`,
+		}},
+		{"/en/", []string{
+			`<link rel="alternate" hreflang="en" href="https://grokking.org/en/">`,
+			`<link rel="alternate" hreflang="vi" href="https://grokking.org/">`,
+		}},
+		{"/en/blog/post", []string{
+			`<link rel="alternate" hreflang="en" href="https://grokking.org/en/blog/post">`,
+			`<link rel="alternate" hreflang="vi" href="https://grokking.org/blog/post">`,
+		}},
+	} {
+		req, _ := http.NewRequest("GET", test.url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		for _, tag := range test.expected {
+			if !strings.Contains(w.Body.String(), tag) {
+				T.Errorf("%v: expect %v, got %v", test.url, tag, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestAbsoluteURL(T *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"/static/a.png":             "https://grokking.org/static/a.png",
+		"blog/":                     "https://grokking.org/blog/",
+		"https://cdn.example/a.png": "https://cdn.example/a.png",
+		"//cdn.example/a.png":       "//cdn.example/a.png",
+	}
+	for url, expected := range tests {
+		if result := absoluteURL("https://grokking.org", url); result != expected {
+			T.Errorf("Expect %q for %q, got %q", expected, url, result)
+		}
+	}
+}
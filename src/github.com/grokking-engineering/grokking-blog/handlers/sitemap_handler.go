@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+// SitemapRule sets priority and changefreq for entries in a directory.
+type SitemapRule struct {
+	Priority   string `json:"priority"`
+	ChangeFreq string `json:"changefreq"`
+}
+
+// SitemapHandler serves "/sitemap.xml" with all entries.
+type SitemapHandler struct {
+	Store *store.Instance
+
+	// Absolute url like "https://grokking.org", use request host if empty.
+	BaseURL string
+
+	Default SitemapRule
+	// Map from directory to rule, the longest matching directory is used.
+	Dirs map[string]SitemapRule
+
+	// Write without indentation
+	Minify bool
+}
+
+func (this *SitemapHandler) Init() {
+	if this.Store == nil {
+		panic("Required object is nil")
+	}
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+func (this *SitemapHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	err := this.Render(w, baseURL(this.BaseURL, req))
+	if err != nil {
+		l.WithError(err).Error("Render sitemap")
+	}
+}
+
+// Render writes the sitemap, for serving or static build.
+func (this *SitemapHandler) Render(w io.Writer, baseURL string) error {
+	entries := this.Store.GetEntries()
+	var paths []string
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	urlSet := sitemapURLSet{}
+	for _, path := range paths {
+		entry := entries[path]
+		rule := this.rule(path, entry)
+		u := sitemapURL{
+			Loc:        baseURL + entryURL(path, entry),
+			ChangeFreq: rule.ChangeFreq,
+			Priority:   rule.Priority,
+		}
+		if lastMod := entry.LastModified(); !lastMod.IsZero() {
+			u.LastMod = lastMod.Format("2006-01-02")
+		}
+		urlSet.URLs = append(urlSet.URLs, u)
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	if !this.Minify {
+		encoder.Indent("", "  ")
+	}
+	return encoder.Encode(urlSet)
+}
+
+// rule returns the rule of the closest directory. Index pages of a
+// directory, e.g. "blog", use the rule of that directory.
+func (this *SitemapHandler) rule(path string, entry *store.Entry) SitemapRule {
+	dirPath := filepath.Dir(path)
+	if entry.IsDir {
+		dirPath = path
+	}
+	for ; ; dirPath = filepath.Dir(dirPath) {
+		if rule, ok := this.Dirs[dirPath]; ok {
+			return rule
+		}
+		if dirPath == "." {
+			return this.Default
+		}
+	}
+}
+
+// RobotsHandler serves "/robots.txt" pointing to the sitemap.
+type RobotsHandler struct {
+	// Absolute url like "https://grokking.org", use request host if empty.
+	BaseURL string
+
+	// Paths of internal routes
+	Disallow []string
+}
+
+func (this *RobotsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	this.Render(w, baseURL(this.BaseURL, req))
+}
+
+// Render writes robots.txt, for serving or static build.
+func (this *RobotsHandler) Render(w io.Writer, baseURL string) error {
+	disallow := ""
+	for _, path := range this.Disallow {
+		disallow += "Disallow: " + path + "\n"
+	}
+	_, err := fmt.Fprintf(w, "User-agent: *\n%v\nSitemap: %v/sitemap.xml\n", disallow, baseURL)
+	return err
+}
+
+func baseURL(configured string, req *http.Request) string {
+	if configured != "" {
+		return strings.TrimSuffix(configured, "/")
+	}
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host
+}
+
+// entryURL returns the absolute path of an entry, e.g. "/blog/".
+func entryURL(path string, entry *store.Entry) string {
+	if path == "." {
+		return "/"
+	}
+	url := "/" + filepath.ToSlash(path)
+	if entry.IsDir {
+		url += "/"
+	}
+	return url
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"testing"
+)
+
+func TestSitemap(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 0, 2)
+	defer cleanup()
+
+	sitemap := &SitemapHandler{
+		Store:   handler.Store,
+		Default: SitemapRule{Priority: "0.5", ChangeFreq: "monthly"},
+		Dirs:    map[string]SitemapRule{"blog": {Priority: "0.8", ChangeFreq: "weekly"}},
+	}
+	sitemap.Init()
+
+	w := get(sitemap, "http://grokking.org/sitemap.xml")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/xml; charset=utf-8" {
+		T.Fatal("Expect sitemap, got", w.Code, w.Header())
+	}
+	var urlSet sitemapURLSet
+	err := xml.Unmarshal(w.Body.Bytes(), &urlSet)
+	if err != nil {
+		T.Fatal(err)
+	}
+
+	expected := []sitemapURL{
+		{"http://grokking.org/", "2016-03-01", "monthly", "0.5"},
+		{"http://grokking.org/blog/", "2016-03-01", "weekly", "0.8"},
+		{"http://grokking.org/blog/post0", "2016-03-01", "weekly", "0.8"},
+		{"http://grokking.org/blog/post1", "2016-03-01", "weekly", "0.8"},
+	}
+	if len(urlSet.URLs) != len(expected) {
+		T.Fatal("Expect urls of all entries, got", urlSet.URLs)
+	}
+	for i, u := range expected {
+		if urlSet.URLs[i] != u {
+			T.Errorf("Expect %v, got %v", u, urlSet.URLs[i])
+		}
+	}
+
+	sitemap.BaseURL = "https://grokking.org/"
+	urlSet = sitemapURLSet{}
+	if err := xml.Unmarshal(get(sitemap, "/sitemap.xml").Body.Bytes(), &urlSet); err != nil || urlSet.URLs[0].Loc != "https://grokking.org/" {
+		T.Error("Expect configured base url, got", urlSet.URLs[0], err)
+	}
+}
+
+func TestRobots(T *testing.T) {
+	robots := &RobotsHandler{Disallow: []string{"/__health__", "/__reload__"}}
+	w := get(robots, "http://grokking.org/robots.txt")
+	expected := "User-agent: *\nDisallow: /__health__\nDisallow: /__reload__\n\nSitemap: http://grokking.org/sitemap.xml\n"
+	if w.Body.String() != expected || w.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		T.Errorf("Expect %q, got %q", expected, w.Body.String())
+	}
+
+	robots = &RobotsHandler{BaseURL: "https://grokking.org"}
+	expected = "User-agent: *\n\nSitemap: https://grokking.org/sitemap.xml\n"
+	if body := get(robots, "/robots.txt").Body.String(); body != expected {
+		T.Errorf("Expect %q, got %q", expected, body)
+	}
+}
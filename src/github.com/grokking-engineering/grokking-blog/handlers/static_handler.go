@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/grokking-engineering/grokking-blog/store"
+)
+
+// Fingerprinted files never change, a new content has a new name.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// StaticHandler serves files of static dir, with the "/static/" prefix
+// stripped. Fingerprinted names returned by the "asset" template function
+// are served as the original file with immutable cache headers. Names from
+// the previous reload are served as the current file, without immutable
+// cache headers, older names are not found. Bundles are served from memory.
+type StaticHandler struct {
+	Store *store.Instance
+	// Serves other names, usually http.FileServer of static dir
+	Files http.Handler
+}
+
+func (this *StaticHandler) Init() {
+	if this.Store == nil || this.Files == nil {
+		panic("Required object is nil")
+	}
+}
+
+func (this *StaticHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	snapshot := this.Store.Snapshot()
+	if snapshot == nil {
+		this.Files.ServeHTTP(w, req)
+		return
+	}
+
+	static := snapshot.Static()
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	name, ok := static.Lookup(path)
+	if ok {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	} else if name, ok = static.LookupStale(path); !ok {
+		name = path
+	}
+
+	if content, modTime, isBundle := static.Bundle(name); isBundle {
+		http.ServeContent(w, req, name, modTime, bytes.NewReader(content))
+		return
+	}
+	if ok {
+		r := *req
+		u := *req.URL
+		u.Path = "/" + name
+		r.URL = &u
+		req = &r
+	}
+	this.Files.ServeHTTP(w, req)
+}
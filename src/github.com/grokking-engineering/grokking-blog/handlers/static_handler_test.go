@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticHandler(T *testing.T) {
+	handler, cleanup := newTestHandler(T, 0, 1)
+	defer cleanup()
+
+	staticDir, err := ioutil.TempDir("", "grokking-static")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(staticDir)
+	ioutil.WriteFile(filepath.Join(staticDir, "main.css"), []byte("body{}"), 0644)
+
+	ioutil.WriteFile(filepath.Join(staticDir, "a.js"), []byte("var a = 1"), 0644)
+	handler.Store.StaticDir = staticDir
+	handler.Store.Bundles = map[string][]string{"site.js": {"a.js"}}
+	handler.Store.Reload()
+	static := &StaticHandler{
+		Store: handler.Store,
+		Files: http.FileServer(http.Dir(staticDir)),
+	}
+	static.Init()
+
+	url, err := handler.Store.Snapshot().Static().URL("main.css")
+	if err != nil {
+		T.Fatal(err)
+	}
+	w := get(static, url[len("/static"):])
+	if w.Code != http.StatusOK || w.Body.String() != "body{}" {
+		T.Fatal("Expect file served, got", w.Code, w.Body.String())
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != immutableCacheControl {
+		T.Error("Expect immutable, got", cc)
+	}
+
+	w = get(static, "/main.css")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "" {
+		T.Error("Expect original name served without cache headers, got", w.Code, w.Header())
+	}
+	if w := get(static, "/main.00000000.css"); w.Code != http.StatusNotFound {
+		T.Error("Expect unknown fingerprint not found, got", w.Code)
+	}
+
+	bundleURL, _ := handler.Store.Snapshot().Static().URL("site.js")
+	for _, url := range []string{bundleURL, "/static/site.js"} {
+		w := get(static, url[len("/static"):])
+		if w.Code != http.StatusOK || w.Body.String() != "var a=1" {
+			T.Error("Expect bundle served, got", url, w.Code, w.Body.String())
+		}
+	}
+
+	// pages rendered before a reload still use the previous names
+	ioutil.WriteFile(filepath.Join(staticDir, "main.css"), []byte("body{color:red}"), 0644)
+	ioutil.WriteFile(filepath.Join(staticDir, "a.js"), []byte("var a = 2"), 0644)
+	handler.Store.Reload()
+	for old, content := range map[string]string{url: "body{color:red}", bundleURL: "var a=2"} {
+		w := get(static, old[len("/static"):])
+		if w.Code != http.StatusOK || w.Body.String() != content {
+			T.Error("Expect current file served for previous name, got", old, w.Code, w.Body.String())
+		}
+		if cc := w.Header().Get("Cache-Control"); cc == immutableCacheControl {
+			T.Error("Expect previous name not immutable", old)
+		}
+	}
+
+	// names are kept for one reload only
+	handler.Store.Reload()
+	if w := get(static, url[len("/static"):]); w.Code != http.StatusNotFound {
+		T.Error("Expect older name not found, got", w.Code)
+	}
+}
@@ -0,0 +1,419 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grokking-engineering/grokking-blog/utils/logs"
+)
+
+const URLPrefix = "/__images__/"
+
+var l = logs.New("images")
+
+var (
+	ErrNotImage = errors.New("Not a supported image")
+	ErrNotFound = errors.New("Image not found")
+	ErrWidth    = errors.New("Image width is not in IMAGE_WIDTHS")
+)
+
+// Image is returned by the "image" template function.
+type Image struct {
+	URL    string
+	Width  int
+	Height int
+
+	// Original image and all smaller variants, for the srcset attribute.
+	SrcSet string
+	// Same as SrcSet with webp variants, empty if webp is disabled.
+	WebPSrcSet string
+}
+
+// Processor generates resized variants of images under ContentDir and
+// StaticDir. Variants are generated lazily on first request and cached in
+// CacheDir:
+//
+//	/__images__/800/blog/post/diagram.png       resized to 800px width
+//	/__images__/800/blog/post/diagram.png.webp  same, in webp format
+//
+// Image urls are either "/static/<file>" or "/<path in content dir>".
+type Processor struct {
+	ContentDir string
+	StaticDir  string
+	CacheDir   string
+	Widths     []int
+
+	// Path to the cwebp binary. WebP variants are disabled when empty.
+	CWebP string
+
+	// widths of Widths, not modified after Init
+	allowed map[int]bool
+
+	// mu only guards the maps, images are processed without it
+	mu       sync.Mutex
+	configs  map[string]imageConfig
+	inflight map[string]*generation
+}
+
+// generation is a variant being generated, concurrent requests of the same
+// variant wait for it.
+type generation struct {
+	done chan struct{}
+	err  error
+}
+
+type imageConfig struct {
+	modTime time.Time
+	width   int
+	height  int
+}
+
+func (this *Processor) Init() {
+	if this.CacheDir == "" {
+		panic("Empty CacheDir")
+	}
+	this.configs = make(map[string]imageConfig)
+	this.inflight = make(map[string]*generation)
+	this.allowed = make(map[int]bool)
+	for _, w := range this.Widths {
+		this.allowed[w] = true
+	}
+}
+
+// ParseWidths parses a comma separated list like "480,800,1200".
+func ParseWidths(s string) ([]int, error) {
+	var widths []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		w, err := strconv.Atoi(part)
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("Invalid image width: %v", part)
+		}
+		widths = append(widths, w)
+	}
+	return widths, nil
+}
+
+func isImage(urlPath string) bool {
+	switch strings.ToLower(path.Ext(urlPath)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// sourcePath maps an image url to its file. Only images are resolved.
+func (this *Processor) sourcePath(urlPath string) (string, error) {
+	if !isImage(urlPath) {
+		return "", ErrNotImage
+	}
+
+	cleanPath := path.Clean("/" + urlPath)
+	baseDir := this.ContentDir
+	if strings.HasPrefix(cleanPath, "/static/") {
+		baseDir = this.StaticDir
+		cleanPath = strings.TrimPrefix(cleanPath, "/static")
+	}
+	for _, part := range strings.Split(cleanPath, "/") {
+		if strings.HasPrefix(part, "_") || strings.HasPrefix(part, ".") {
+			return "", ErrNotFound
+		}
+	}
+	return filepath.Join(baseDir, filepath.FromSlash(cleanPath)), nil
+}
+
+func (this *Processor) config(urlPath string) (imageConfig, error) {
+	srcPath, err := this.sourcePath(urlPath)
+	if err != nil {
+		return imageConfig{}, err
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return imageConfig{}, ErrNotFound
+	}
+
+	this.mu.Lock()
+	cfg, ok := this.configs[srcPath]
+	this.mu.Unlock()
+	if ok && cfg.modTime.Equal(info.ModTime()) {
+		return cfg, nil
+	}
+
+	file, err := os.Open(srcPath)
+	if err != nil {
+		return imageConfig{}, err
+	}
+	defer file.Close()
+	imgCfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return imageConfig{}, err
+	}
+
+	cfg = imageConfig{
+		modTime: info.ModTime(),
+		width:   imgCfg.Width,
+		height:  imgCfg.Height,
+	}
+	this.mu.Lock()
+	this.configs[srcPath] = cfg
+	this.mu.Unlock()
+	return cfg, nil
+}
+
+func variantURL(urlPath string, width int) string {
+	return URLPrefix + strconv.Itoa(width) + path.Clean("/"+urlPath)
+}
+
+// Get returns the url and dimensions of the image resized to width, which
+// must be one of Widths. The original is used when it is not wider than
+// width.
+func (this *Processor) Get(urlPath string, width int) (*Image, error) {
+	cfg, err := this.config(urlPath)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &Image{URL: urlPath, Width: cfg.width, Height: cfg.height}
+	if width > 0 && width < cfg.width {
+		if !this.allowed[width] {
+			return nil, ErrWidth
+		}
+		img.URL = variantURL(urlPath, width)
+		img.Width = width
+		img.Height = scaleHeight(cfg, width)
+	}
+
+	var srcset, webp []string
+	for _, w := range this.Widths {
+		if w < img.Width {
+			srcset = append(srcset, fmt.Sprintf("%v %vw", variantURL(urlPath, w), w))
+			webp = append(webp, fmt.Sprintf("%v.webp %vw", variantURL(urlPath, w), w))
+		}
+	}
+	srcset = append(srcset, fmt.Sprintf("%v %vw", img.URL, img.Width))
+	webp = append(webp, fmt.Sprintf("%v.webp %vw", variantURL(urlPath, img.Width), img.Width))
+
+	img.SrcSet = strings.Join(srcset, ", ")
+	if this.CWebP != "" {
+		img.WebPSrcSet = strings.Join(webp, ", ")
+	}
+	return img, nil
+}
+
+func scaleHeight(cfg imageConfig, width int) int {
+	h := (cfg.height*width + cfg.width/2) / cfg.width
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+var reImgTag = regexp.MustCompile(`<img [^>]*src="(/[^"]*)"[^>]*>`)
+
+// RewriteHTML adds srcset, sizes, width and height to local images in
+// rendered article content. When webp is enabled, images are wrapped in a
+// <picture> element.
+func (this *Processor) RewriteHTML(html template.HTML) template.HTML {
+	result := reImgTag.ReplaceAllStringFunc(string(html), func(tag string) string {
+		if strings.Contains(tag, " srcset=") {
+			return tag
+		}
+
+		urlPath := reImgTag.FindStringSubmatch(tag)[1]
+		img, err := this.Get(urlPath, 0)
+		if err != nil {
+			if err != ErrNotImage {
+				l.WithError(err).WithFields(logs.M{
+					"url": urlPath,
+				}).Error("Unable to process image")
+			}
+			return tag
+		}
+
+		attrs := fmt.Sprintf(` srcset="%v" sizes="(max-width: %vpx) 100vw, %vpx" width="%v" height="%v"`,
+			img.SrcSet, img.Width, img.Width, img.Width, img.Height)
+		newTag := strings.TrimRight(strings.TrimSuffix(strings.TrimSuffix(tag, ">"), "/"), " ") + attrs
+		if strings.HasSuffix(tag, "/>") {
+			newTag += " />"
+		} else {
+			newTag += ">"
+		}
+
+		if img.WebPSrcSet == "" {
+			return newTag
+		}
+		return fmt.Sprintf(`<picture><source type="image/webp" srcset="%v">%v</picture>`,
+			img.WebPSrcSet, newTag)
+	})
+	return template.HTML(result)
+}
+
+// ServeHTTP serves variants, req.URL.Path must be stripped of URLPrefix:
+// "<width>/<image url>[.webp]".
+func (this *Processor) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 {
+		http.NotFound(w, req)
+		return
+	}
+	width, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	urlPath := "/" + parts[1]
+	webp := strings.HasSuffix(urlPath, ".webp")
+	if webp {
+		if this.CWebP == "" {
+			http.NotFound(w, req)
+			return
+		}
+		urlPath = strings.TrimSuffix(urlPath, ".webp")
+	}
+
+	// webp of the original image has its width
+	if !this.allowed[width] {
+		cfg, err := this.config(urlPath)
+		if !webp || err != nil || cfg.width != width {
+			http.NotFound(w, req)
+			return
+		}
+	}
+
+	cachePath, err := this.variant(urlPath, width, webp)
+	if err == ErrNotImage || err == ErrNotFound {
+		http.NotFound(w, req)
+		return
+	}
+	if err != nil {
+		l.WithError(err).WithFields(logs.M{
+			"url":   urlPath,
+			"width": width,
+		}).Error("Unable to generate image")
+		http.Error(w, "500 Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeFile(w, req, cachePath)
+}
+
+// variant returns the path of a cached variant, generating it when missing
+// or older than the source.
+func (this *Processor) variant(urlPath string, width int, webp bool) (string, error) {
+	srcPath, err := this.sourcePath(urlPath)
+	if err != nil {
+		return "", err
+	}
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return "", ErrNotFound
+	}
+
+	cachePath := filepath.Join(this.CacheDir, strconv.Itoa(width), filepath.FromSlash(path.Clean("/"+urlPath)))
+	if webp {
+		cachePath += ".webp"
+	}
+
+	// concurrent requests of the same variant wait for one generation, other
+	// variants are generated in parallel
+	this.mu.Lock()
+	if g := this.inflight[cachePath]; g != nil {
+		this.mu.Unlock()
+		<-g.done
+		return cachePath, g.err
+	}
+	g := &generation{done: make(chan struct{})}
+	this.inflight[cachePath] = g
+	this.mu.Unlock()
+
+	g.err = this.generate(srcPath, srcInfo, cachePath, width, webp)
+
+	this.mu.Lock()
+	delete(this.inflight, cachePath)
+	this.mu.Unlock()
+	close(g.done)
+	return cachePath, g.err
+}
+
+// generate writes the variant to cachePath, unless it is newer than the
+// source.
+func (this *Processor) generate(srcPath string, srcInfo os.FileInfo, cachePath string, width int, webp bool) error {
+	info, err := os.Stat(cachePath)
+	if err == nil && !info.ModTime().Before(srcInfo.ModTime()) {
+		return nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(cachePath), 0755)
+	if err != nil {
+		return err
+	}
+
+	if webp {
+		// like below, write to a temporary file so a partial image is never served
+		tmpFile, err := ioutil.TempFile(filepath.Dir(cachePath), ".tmp-")
+		if err != nil {
+			return err
+		}
+		tmpFile.Close()
+		defer os.Remove(tmpFile.Name())
+
+		// cwebp resizes keeping aspect ratio when height is 0
+		output, err := exec.Command(this.CWebP, "-quiet", "-q", "80",
+			"-resize", strconv.Itoa(width), "0", srcPath, "-o", tmpFile.Name()).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("cwebp: %v: %s", err, output)
+		}
+		return os.Rename(tmpFile.Name(), cachePath)
+	}
+
+	file, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	src, format, err := image.Decode(file)
+	if err != nil {
+		return err
+	}
+
+	dst := Resize(src, width)
+	tmpFile, err := ioutil.TempFile(filepath.Dir(cachePath), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if format == "jpeg" {
+		err = jpeg.Encode(tmpFile, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(tmpFile, dst)
+	}
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+	err = tmpFile.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), cachePath)
+}
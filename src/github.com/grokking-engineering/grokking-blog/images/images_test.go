@@ -0,0 +1,183 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testProcessor(T *testing.T) (*Processor, func()) {
+	dir, err := ioutil.TempDir("", "images")
+	if err != nil {
+		T.Fatal(err)
+	}
+
+	err = os.MkdirAll(filepath.Join(dir, "content", "post"), 0755)
+	if err != nil {
+		T.Fatal(err)
+	}
+	file, err := os.Create(filepath.Join(dir, "content", "post", "a.png"))
+	if err != nil {
+		T.Fatal(err)
+	}
+	err = png.Encode(file, image.NewRGBA(image.Rect(0, 0, 1000, 500)))
+	file.Close()
+	if err != nil {
+		T.Fatal(err)
+	}
+
+	p := &Processor{
+		ContentDir: filepath.Join(dir, "content"),
+		StaticDir:  filepath.Join(dir, "static"),
+		CacheDir:   filepath.Join(dir, "cache"),
+		Widths:     []int{400, 800, 1200},
+	}
+	p.Init()
+	return p, func() { os.RemoveAll(dir) }
+}
+
+func TestGet(T *testing.T) {
+	p, cleanup := testProcessor(T)
+	defer cleanup()
+
+	img, err := p.Get("/post/a.png", 800)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if img.URL != "/__images__/800/post/a.png" || img.Width != 800 || img.Height != 400 {
+		T.Error("Expect resized image", img)
+	}
+	if img.SrcSet != "/__images__/400/post/a.png 400w, /__images__/800/post/a.png 800w" {
+		T.Error("Expect srcset", img.SrcSet)
+	}
+
+	img, err = p.Get("/post/a.png", 2000)
+	if err != nil || img.URL != "/post/a.png" || img.Width != 1000 {
+		T.Error("Expect original image", img, err)
+	}
+
+	_, err = p.Get("/_private/a.png", 800)
+	if err != ErrNotFound {
+		T.Error("Expect not found", err)
+	}
+
+	_, err = p.Get("/post/a.png", 640)
+	if err != ErrWidth {
+		T.Error("Expect widths not configured rejected", err)
+	}
+}
+
+func TestServeWidths(T *testing.T) {
+	p, cleanup := testProcessor(T)
+	defer cleanup()
+
+	for url, code := range map[string]int{
+		"/400/post/a.png":       http.StatusOK,
+		"/640/post/a.png":       http.StatusNotFound,
+		"/1000/post/a.png":      http.StatusNotFound,
+		"/400/post/missing.png": http.StatusNotFound,
+	} {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, req)
+		if w.Code != code {
+			T.Error("Expect", code, "for", url, "got", w.Code)
+		}
+	}
+}
+
+func TestVariant(T *testing.T) {
+	p, cleanup := testProcessor(T)
+	defer cleanup()
+
+	cachePath, err := p.variant("/post/a.png", 400, false)
+	if err != nil {
+		T.Fatal(err)
+	}
+	file, err := os.Open(cachePath)
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer file.Close()
+	cfg, err := png.DecodeConfig(file)
+	if err != nil || cfg.Width != 400 || cfg.Height != 200 {
+		T.Error("Expect 400x200 image", cfg, err)
+	}
+}
+
+func TestVariantConcurrent(T *testing.T) {
+	p, cleanup := testProcessor(T)
+	defer cleanup()
+
+	errs := make(chan error)
+	for i := 0; i < 10; i++ {
+		width := p.Widths[i%2]
+		go func() {
+			_, err := p.variant("/post/a.png", width, false)
+			errs <- err
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err != nil {
+			T.Error(err)
+		}
+	}
+	if len(p.inflight) != 0 {
+		T.Error("Expect no generation in flight", p.inflight)
+	}
+}
+
+func TestRewriteHTML(T *testing.T) {
+	p, cleanup := testProcessor(T)
+	defer cleanup()
+
+	html := string(p.RewriteHTML(`<p><img src="/post/a.png" alt="A" /> <img src="http://x/b.png"></p>`))
+	if !strings.Contains(html, `<img src="/post/a.png" alt="A" srcset="/__images__/400/post/a.png 400w, /__images__/800/post/a.png 800w, /post/a.png 1000w" sizes="(max-width: 1000px) 100vw, 1000px" width="1000" height="500" />`) {
+		T.Error("Expect srcset", html)
+	}
+	if !strings.Contains(html, `<img src="http://x/b.png">`) {
+		T.Error("Expect external image unchanged", html)
+	}
+}
+
+func TestVariantWebP(T *testing.T) {
+	p, cleanup := testProcessor(T)
+	defer cleanup()
+
+	// fake cwebp writing its output, then exiting with $1
+	cwebp := func(code int) string {
+		path := filepath.Join(filepath.Dir(p.CacheDir), fmt.Sprintf("cwebp%v.sh", code))
+		script := fmt.Sprintf("#!/bin/sh\nwhile [ $# -gt 1 ]; do [ \"$1\" = -o ] && out=$2; shift; done\nprintf webp > \"$out\"\nexit %v\n", code)
+		err := ioutil.WriteFile(path, []byte(script), 0755)
+		if err != nil {
+			T.Fatal(err)
+		}
+		return path
+	}
+
+	// a failed conversion leaves nothing to serve
+	p.CWebP = cwebp(1)
+	if _, err := p.variant("/post/a.png", 400, true); err == nil {
+		T.Fatal("Expect cwebp error")
+	}
+	matches, _ := filepath.Glob(filepath.Join(p.CacheDir, "400", "post", "*"))
+	if len(matches) != 0 {
+		T.Error("Expect no files left, got", matches)
+	}
+
+	p.CWebP = cwebp(0)
+	cachePath, err := p.variant("/post/a.png", 400, true)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(cachePath); err != nil || string(data) != "webp" || !strings.HasSuffix(cachePath, ".png.webp") {
+		T.Error("Expect webp variant, got", cachePath, string(data), err)
+	}
+}
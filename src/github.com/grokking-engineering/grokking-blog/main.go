@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"os"
 
 	"github.com/grokking-engineering/grokking-blog/gserver"
 	"github.com/grokking-engineering/grokking-blog/utils/load-config"
@@ -23,5 +24,36 @@ func main() {
 		l.WithError(err).Fatal("Loading config")
 	}
 
-	l.Fatal(gserver.Start(cfg))
+	// grokking-blog [-config-file FILE] check [-json]
+	if flag.Arg(0) == "check" {
+		checkFlags := flag.NewFlagSet("check", flag.ExitOnError)
+		asJSON := checkFlags.Bool("json", false, "Print problems as json")
+		checkFlags.Parse(flag.Args()[1:])
+		if !gserver.Check(cfg, os.Stdout, *asJSON) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// grokking-blog [-config-file FILE] bundle [-out DIR]
+	if flag.Arg(0) == "bundle" {
+		bundleFlags := flag.NewFlagSet("bundle", flag.ExitOnError)
+		outDir := bundleFlags.String("out", "public/static", "Write bundles to dir")
+		bundleFlags.Parse(flag.Args()[1:])
+		err := gserver.WriteBundles(cfg, os.Stdout, *outDir)
+		if err != nil {
+			l.WithError(err).Fatal("Writing bundles")
+		}
+		return
+	}
+
+	err = gserver.Start(cfg, func() (gserver.Config, error) {
+		var newCfg gserver.Config
+		err := loadConfig.FromFileAndEnv(&newCfg, *flConfigFile)
+		return newCfg, err
+	})
+	if err != nil {
+		l.WithError(err).Fatal("Server stopped")
+	}
+	l.Println("Server stopped")
 }
@@ -0,0 +1,26 @@
+package middlewares
+
+import "net/http"
+
+type CacheControl struct {
+	Value string
+}
+
+// NewCacheControl sets Cache-Control of responses, e.g.
+// "public, max-age=86400". Empty value sends no header.
+func NewCacheControl(value string) func(http.Handler) http.Handler {
+	c := CacheControl{Value: value}
+	return c.factory
+}
+
+func (c CacheControl) factory(next http.Handler) http.Handler {
+	if c.Value == "" {
+		return next
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", c.Value)
+			next.ServeHTTP(w, r)
+		})
+}
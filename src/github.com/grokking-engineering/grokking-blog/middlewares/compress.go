@@ -0,0 +1,249 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Responses smaller than this are not worth compressing.
+const DefaultCompressMinSize = 1024
+
+// Brotli level for compressing on the fly, higher levels are too slow.
+const brotliLevel = 4
+
+type Compress struct {
+	MinSize int
+}
+
+// NewCompress compresses text responses of at least minSize bytes with
+// Brotli or gzip when the client accepts it. Responses which already have a
+// Content-Encoding, e.g. from Precompressed, are left as is.
+func NewCompress(minSize int) func(http.Handler) http.Handler {
+	c := Compress{MinSize: minSize}
+	return c.factory
+}
+
+// encoder is implemented by *gzip.Writer and *brotli.Writer.
+type encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	"br": {
+		New: func() interface{} {
+			return brotli.NewWriterLevel(nil, brotliLevel)
+		},
+	},
+	"gzip": {
+		New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+			return w
+		},
+	},
+}
+
+func (c Compress) factory(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), "Accept-Encoding")
+			encoding := dynamicEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			wrapper := &compressWriter{ResponseWriter: w, minSize: c.MinSize, encoding: encoding}
+			defer wrapper.close()
+			next.ServeHTTP(wrapper, r)
+		})
+}
+
+// compressWriter buffers the beginning of the response to decide whether to
+// compress it, headers are written with the decision.
+type compressWriter struct {
+	http.ResponseWriter
+
+	minSize  int
+	encoding string
+	status   int
+	buf      []byte
+
+	decided bool
+	enc     encoder
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// decide writes headers and buffered data.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	header := w.Header()
+	if w.status == http.StatusNotModified {
+		// 304 has the ETag of the response it validates, which may be compressed
+		if header.Get("Content-Encoding") == "" {
+			weakenETag(header)
+		}
+	} else if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if len(w.buf) >= w.minSize && w.status == http.StatusOK &&
+		header.Get("Content-Encoding") == "" && isCompressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		// the ETag is of the uncompressed page
+		weakenETag(header)
+		w.enc = encoderPools[w.encoding].Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// nothing written, e.g. after a panic
+			return
+		}
+		w.decide()
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(nil)
+		encoderPools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+func weakenETag(header http.Header) {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+}
+
+// addVary adds value to the Vary header once.
+func addVary(header http.Header, value string) {
+	for _, v := range header["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
+
+func isCompressible(contentType string) bool {
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+	if strings.HasPrefix(contentType, "text/") {
+		return true
+	}
+	switch contentType {
+	case "application/javascript", "application/json", "application/xml",
+		"application/rss+xml", "application/atom+xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// dynamicEncoding returns the encoding to compress a response with, or ""
+// when the client accepts none. Brotli is only used when named by the
+// client, "*" alone gets gzip.
+func dynamicEncoding(header string) string {
+	br, _ := encodingQuality(header, "br")
+	gz := acceptedQuality(header, "gzip")
+	switch {
+	case br > 0 && br >= gz:
+		return "br"
+	case gz > 0:
+		return "gzip"
+	}
+	return ""
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header allows
+// encoding, with a non zero quality.
+func acceptsEncoding(header, encoding string) bool {
+	return acceptedQuality(header, encoding) > 0
+}
+
+// acceptedQuality returns the quality of encoding, or of "*" when encoding
+// is not named.
+func acceptedQuality(header, encoding string) float64 {
+	q, named := encodingQuality(header, encoding)
+	if !named {
+		// explicit value overrides "*"
+		q, _ = encodingQuality(header, "*")
+	}
+	return q
+}
+
+// encodingQuality returns the quality of encoding in the Accept-Encoding
+// header, and whether it is named there.
+func encodingQuality(header, encoding string) (float64, bool) {
+	for _, part := range strings.Split(header, ",") {
+		name, q := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				var err error
+				q, err = strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					q = 0
+				}
+			}
+		}
+		if strings.ToLower(strings.TrimSpace(name)) == encoding {
+			return q, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,153 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func serve(handler http.Handler, url, acceptEncoding string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", url, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompress(T *testing.T) {
+	page := strings.Repeat("<p>Hello</p>", 200)
+	handler := NewCompress(DefaultCompressMinSize)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/page":
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Header().Set("ETag", `"abc"`)
+				w.Write([]byte(page))
+			case "/small":
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Write([]byte("<p>Hello</p>"))
+			case "/image":
+				w.Header().Set("Content-Type", "image/png")
+				w.Write([]byte(page))
+			case "/notmodified":
+				w.Header().Set("ETag", `"abc"`)
+				w.WriteHeader(http.StatusNotModified)
+			}
+		}))
+
+	w := serve(handler, "/page", "gzip, deflate")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		T.Fatal("Expect gzip, got", w.Header())
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" || w.Header().Get("ETag") != `W/"abc"` {
+		T.Error("Unexpected headers", w.Header())
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		T.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(reader)
+	if string(body) != page {
+		T.Error("Unexpected body", len(body))
+	}
+
+	for _, test := range []struct {
+		url, acceptEncoding string
+		code                int
+	}{
+		{"/page", "", http.StatusOK},
+		{"/page", "gzip;q=0, deflate", http.StatusOK},
+		{"/page", "*;q=1, gzip;q=0", http.StatusOK},
+		{"/small", "gzip", http.StatusOK},
+		{"/image", "gzip", http.StatusOK},
+		{"/notmodified", "gzip", http.StatusNotModified},
+	} {
+		w := serve(handler, test.url, test.acceptEncoding)
+		if w.Code != test.code || w.Header().Get("Content-Encoding") != "" {
+			T.Errorf("%v %q: expect %v uncompressed, got %v %v", test.url, test.acceptEncoding, test.code, w.Code, w.Header())
+		}
+	}
+	if w := serve(handler, "/page", "*"); w.Header().Get("Content-Encoding") != "gzip" {
+		T.Error("Expect gzip for *")
+	}
+
+	// Brotli is preferred when named
+	for _, acceptEncoding := range []string{"gzip, deflate, br", "br;q=0.5, gzip;q=0.5", "br, *"} {
+		w := serve(handler, "/page", acceptEncoding)
+		if w.Header().Get("Content-Encoding") != "br" || w.Header().Get("ETag") != `W/"abc"` {
+			T.Fatalf("%q: expect br, got %v", acceptEncoding, w.Header())
+		}
+		body, err := ioutil.ReadAll(brotli.NewReader(w.Body))
+		if err != nil || string(body) != page {
+			T.Error("Unexpected body", len(body), err)
+		}
+	}
+	for _, acceptEncoding := range []string{"br;q=0, gzip", "br;q=0.5, gzip"} {
+		if w := serve(handler, "/page", acceptEncoding); w.Header().Get("Content-Encoding") != "gzip" {
+			T.Errorf("%q: expect gzip, got %v", acceptEncoding, w.Header())
+		}
+	}
+
+	// 304 validates the gzipped response, so its ETag is weak too
+	if etag := serve(handler, "/notmodified", "gzip").Header().Get("ETag"); etag != `W/"abc"` {
+		T.Error("Expect weak ETag on 304, got", etag)
+	}
+	if etag := serve(handler, "/notmodified", "").Header().Get("ETag"); etag != `"abc"` {
+		T.Error("Expect strong ETag without gzip, got", etag)
+	}
+}
+
+func TestPrecompressed(T *testing.T) {
+	dir, err := ioutil.TempDir("", "grokking-static")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"site.css":    "body{}",
+		"site.css.gz": "gzipped",
+		"site.css.br": "brotli",
+		"app.js":      "var a",
+		"app.js.gz":   "stale",
+	}
+	// Set times explicitly: files written in map order may get different mtimes.
+	modTime := time.Now().Add(-time.Hour)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, []byte(content), 0644)
+		os.Chtimes(path, modTime, modTime)
+	}
+	old := modTime.Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "app.js.gz"), old, old)
+
+	handler := NewPrecompressed(dir)(http.FileServer(http.Dir(dir)))
+	for _, test := range []struct {
+		url, acceptEncoding string
+		encoding, body      string
+	}{
+		{"/site.css", "gzip, br", "br", "brotli"},
+		{"/site.css", "gzip", "gzip", "gzipped"},
+		{"/site.css", "", "", "body{}"},
+		{"/app.js", "gzip", "", "var a"},
+	} {
+		w := serve(handler, test.url, test.acceptEncoding)
+		if w.Header().Get("Content-Encoding") != test.encoding || w.Body.String() != test.body {
+			T.Errorf("%v %q: expect %q %q, got %q %q", test.url, test.acceptEncoding,
+				test.encoding, test.body, w.Header().Get("Content-Encoding"), w.Body.String())
+		}
+		if test.encoding != "" && !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+			T.Error("Expect type of original file, got", w.Header().Get("Content-Type"))
+		}
+	}
+}
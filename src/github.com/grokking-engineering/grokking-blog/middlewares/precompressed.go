@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+type Precompressed struct {
+	Dir string
+}
+
+// NewPrecompressed serves "file.br" or "file.gz" from dir in place of "file"
+// when the client accepts it and the compressed file is not older than the
+// original. Request paths are relative to dir, as given to http.FileServer.
+func NewPrecompressed(dir string) func(http.Handler) http.Handler {
+	p := Precompressed{Dir: dir}
+	return p.factory
+}
+
+var precompressedEncodings = []struct {
+	encoding, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+func (p Precompressed) factory(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" && r.Method != "HEAD" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			name := filepath.Join(p.Dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+			info, err := os.Stat(name)
+			if err != nil || info.IsDir() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			addVary(w.Header(), "Accept-Encoding")
+			acceptEncoding := r.Header.Get("Accept-Encoding")
+			for _, enc := range precompressedEncodings {
+				if !acceptsEncoding(acceptEncoding, enc.encoding) {
+					continue
+				}
+				file, err := os.Open(name + enc.ext)
+				if err != nil {
+					continue
+				}
+				compressedInfo, err := file.Stat()
+				if err != nil || compressedInfo.ModTime().Before(info.ModTime()) {
+					file.Close()
+					continue
+				}
+
+				contentType := mime.TypeByExtension(filepath.Ext(name))
+				if contentType == "" {
+					contentType = "application/octet-stream"
+				}
+				w.Header().Set("Content-Type", contentType)
+				w.Header().Set("Content-Encoding", enc.encoding)
+				http.ServeContent(w, r, name, compressedInfo.ModTime(), file)
+				file.Close()
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+}
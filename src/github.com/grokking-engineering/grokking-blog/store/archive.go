@@ -0,0 +1,111 @@
+package store
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+)
+
+type ArchiveYear struct {
+	Year   int
+	Path   template.URL
+	Months []*ArchiveMonth
+	Count  int
+}
+
+type ArchiveMonth struct {
+	Year  int
+	Month time.Month
+	Path  template.URL
+
+	SortedArticles []*Article
+}
+
+// ArchivePage is passed to _layout_archive.tpl.html. Year and Month are nil
+// on "/archive/", Month is nil on "/archive/<year>/".
+type ArchivePage struct {
+	Years []*ArchiveYear
+	Year  *ArchiveYear
+	Month *ArchiveMonth
+}
+
+// buildArchive groups articles by year and month, newest first.
+// sortedArticles must be sorted by date.
+func buildArchive(sortedArticles []*Article, pathPrefix string) []*ArchiveYear {
+	var years []*ArchiveYear
+	for i := len(sortedArticles) - 1; i >= 0; i-- {
+		article := sortedArticles[i]
+		year, month, _ := article.Date.Date()
+
+		if len(years) == 0 || years[len(years)-1].Year != year {
+			years = append(years, &ArchiveYear{
+				Year: year,
+				Path: template.URL(fmt.Sprintf("%varchive/%04d/", pathPrefix, year)),
+			})
+		}
+		y := years[len(years)-1]
+
+		if len(y.Months) == 0 || y.Months[len(y.Months)-1].Month != month {
+			y.Months = append(y.Months, &ArchiveMonth{
+				Year:  year,
+				Month: month,
+				Path:  template.URL(fmt.Sprintf("%varchive/%04d/%02d/", pathPrefix, year, month)),
+			})
+		}
+		m := y.Months[len(y.Months)-1]
+		m.SortedArticles = append(m.SortedArticles, article)
+		y.Count++
+	}
+	return years
+}
+
+// addArchivePages generates "archive", "archive/<year>" and
+// "archive/<year>/<month>" entries rendered with layout.
+func addArchivePages(data *Data, layout *template.Template) error {
+	add := func(path string, title string, page *ArchivePage, date time.Time) error {
+		if data.Entries[path] != nil {
+			return fmt.Errorf("Archive page conflicts with content: %v", path)
+		}
+		data.Entries[path] = &Entry{
+			Article: &Article{
+				Title: title,
+				Path:  template.URL(data.PathPrefix + path + "/"),
+				Date:  date,
+			},
+			Layout:      layout,
+			IsDir:       true,
+			IsGenerated: true,
+			Data:        page,
+		}
+		return nil
+	}
+
+	var latest time.Time
+	if len(data.Archive) > 0 {
+		latest = data.Archive[0].Months[0].SortedArticles[0].Date
+	}
+	err := add("archive", "Archive", &ArchivePage{Years: data.Archive}, latest)
+	if err != nil {
+		return err
+	}
+
+	for _, y := range data.Archive {
+		path := fmt.Sprintf("archive/%04d", y.Year)
+		page := &ArchivePage{Years: data.Archive, Year: y}
+		err := add(path, fmt.Sprint(y.Year), page, y.Months[0].SortedArticles[0].Date)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range y.Months {
+			path := fmt.Sprintf("archive/%04d/%02d", y.Year, m.Month)
+			page := &ArchivePage{Years: data.Archive, Year: y, Month: m}
+			title := fmt.Sprintf("%v %v", m.Month, y.Year)
+			err := add(path, title, page, m.SortedArticles[0].Date)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
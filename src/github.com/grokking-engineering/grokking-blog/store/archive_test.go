@@ -0,0 +1,115 @@
+package store
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArticleByDate(T *testing.T) {
+	date := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	a := &Article{Title: "a", Path: "blog/b", Date: date}
+	b := &Article{Title: "b", Path: "blog/a", Date: date}
+	c := &Article{Title: "c", Path: "blog/c", Date: date.AddDate(0, 0, -1)}
+
+	// same date is ordered by path, whatever the input order
+	for _, articles := range [][]*Article{{a, b, c}, {b, c, a}, {c, a, b}} {
+		sort.Sort(articleByDate(articles))
+		if result := strings.Join(titles(articles), " "); result != "c b a" {
+			T.Error("Expect c b a, got", result)
+		}
+	}
+}
+
+func TestBuildArchive(T *testing.T) {
+	article := func(title, date string) *Article {
+		d, err := ParseDate(date)
+		if err != nil {
+			T.Fatal(err)
+		}
+		return &Article{Title: title, Date: d}
+	}
+	sorted := []*Article{
+		article("a", "20-12-2015"),
+		article("b", "01-03-2016"),
+		article("c", "15-03-2016"),
+		article("d", "02-05-2016"),
+	}
+
+	years := buildArchive(sorted, "en/")
+	if len(years) != 2 || years[0].Year != 2016 || years[1].Year != 2015 {
+		T.Fatal("Expect years newest first, got", years)
+	}
+	y := years[0]
+	if y.Count != 3 || y.Path != "en/archive/2016/" || len(y.Months) != 2 {
+		T.Error("Expect year 2016, got", y)
+	}
+	if m := y.Months[0]; m.Month != time.May || m.Path != "en/archive/2016/05/" || len(m.SortedArticles) != 1 {
+		T.Error("Expect May first, got", m)
+	}
+	if m := y.Months[1]; strings.Join(titles(m.SortedArticles), " ") != "c b" {
+		T.Error("Expect articles of March newest first, got", titles(m.SortedArticles))
+	}
+
+	if years := buildArchive(nil, ""); len(years) != 0 {
+		T.Error("Expect empty archive, got", years)
+	}
+}
+
+func TestArchivePages(T *testing.T) {
+	dir := writeFiles(T, map[string]string{
+		"_layout_main.tpl.html":    `{{.}}`,
+		"_layout.tpl.html":         `{{.Title}}`,
+		"_layout_archive.tpl.html": `{{if .Month}}{{range .Month.SortedArticles}}{{.Title}} {{end}}{{else if .Year}}{{.Year.Count}}{{else}}{{len .Years}}{{end}}`,
+		"index.md":                 "# Home\n\n> 01-03-2016\n\nHome\n",
+		"a.md":                     "# A\n\n> 01-03-2016\n\nA\n",
+		"blog/index.md":            "# Blog\n\n> 01-01-2016\n\nBlog\n",
+		"blog/old.md":              "# Old\n\n> 20-12-2015\n\nOld\n",
+		"blog/new.md":              "# New\n\n> 02-05-2016\n\nNew\n",
+	})
+	defer os.RemoveAll(dir)
+
+	data, errs := loadDir(T, dir)
+	if errs != nil {
+		T.Fatal(errs)
+	}
+
+	// index.md are not listed
+	expected := map[string]string{
+		"archive":         "2",
+		"archive/2016":    "2",
+		"archive/2016/03": "A ",
+		"archive/2016/05": "New ",
+		"archive/2015/12": "Old ",
+	}
+	for path, content := range expected {
+		entry := data.Entries[path]
+		if entry == nil || !entry.IsGenerated {
+			T.Error("Expect archive page", path)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		err := entry.Layout.Execute(buf, entry.TemplateData())
+		if err != nil || buf.String() != content {
+			T.Errorf("Expect %q for %v, got %q %v", content, path, buf.String(), err)
+		}
+	}
+	if entry := data.Entries["archive/2016"]; entry.Article.Path != "archive/2016/" || entry.Article.Date.Month() != time.May {
+		T.Error("Expect year page dated by its newest article, got", entry.Article)
+	}
+
+	// generated pages must not hide content
+	os.Mkdir(filepath.Join(dir, "archive"), 0755)
+	err := ioutil.WriteFile(filepath.Join(dir, "archive", "index.md"), []byte("# Archive\n\n> 01-03-2016\n\nArchive\n"), 0644)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if _, errs := loadDir(T, dir); len(errs) != 1 || errs[0].Phase != "generate" {
+		T.Error("Expect conflict reported, got", errs)
+	}
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Author is loaded from "_authors/<id>.json":
+//
+//	{
+//	  "name": "Thanh Tran",
+//	  "bio": "Backend engineer",
+//	  "avatar": "/static/authors/thanh.jpg",
+//	  "links": {"github": "https://github.com/thanh"}
+//	}
+type Author struct {
+	ID     string            `json:"-"`
+	Name   string            `json:"name"`
+	Bio    string            `json:"bio"`
+	Avatar string            `json:"avatar"`
+	Links  map[string]string `json:"links"`
+
+	// Url of the author page, empty when there is no author layout.
+	Path template.URL `json:"-"`
+
+	SortedArticles []*Article `json:"-"`
+}
+
+func loadAuthors(dirPath string) (map[string]*Author, error) {
+	authors := make(map[string]*Author)
+	files, err := ioutil.ReadDir(dirPath)
+	if os.IsNotExist(err) {
+		return authors, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(dirPath, name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		author := &Author{ID: strings.TrimSuffix(name, ".json")}
+		err = json.Unmarshal(data, author)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+		if author.Name == "" {
+			author.Name = author.ID
+		}
+		authors[author.ID] = author
+	}
+	return authors, nil
+}
+
+// linkAuthors collects articles of each author. Unknown authors are
+// removed from articles and returned as errors.
+func linkAuthors(data *Data) []error {
+	var errs []error
+	for _, article := range data.SortedArticles {
+		ids := article.Authors[:0]
+		for _, id := range article.Authors {
+			author := data.Authors[id]
+			if author == nil {
+				errs = append(errs, fmt.Errorf("Unknown author %q in %v", id, article.Path))
+				continue
+			}
+			author.SortedArticles = append(author.SortedArticles, article)
+			ids = append(ids, id)
+		}
+		article.Authors = ids
+	}
+	return errs
+}
+
+// addAuthorPages generates "authors/<id>" entries rendered with layout.
+func addAuthorPages(data *Data, layout *template.Template) error {
+	for id, author := range data.Authors {
+		path := "authors/" + id
+		if data.Entries[path] != nil {
+			return fmt.Errorf("Author page conflicts with content: %v", path)
+		}
+
+		author.Path = template.URL(data.PathPrefix + path + "/")
+		article := &Article{
+			Title: author.Name,
+			Short: author.Bio,
+			Path:  author.Path,
+		}
+		if n := len(author.SortedArticles); n > 0 {
+			article.Date = author.SortedArticles[n-1].Date
+		}
+
+		data.Entries[path] = &Entry{
+			Article:     article,
+			Layout:      layout,
+			IsDir:       true,
+			IsGenerated: true,
+			Data:        author,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var authorFiles = map[string]string{
+	"_layout_main.tpl.html":   `{{.}}`,
+	"_layout.tpl.html":        `{{.Title}}`,
+	"_authors/thanh.json":     `{"name": "Thanh Tran", "bio": "Backend", "links": {"github": "https://github.com/thanh"}}`,
+	"_authors/huy.json":       `{}`,
+	"_authors/README.txt":     "not a profile",
+	"_layout_author.tpl.html": `{{.Name}}:{{range .SortedArticles}} {{.Title}}{{end}}`,
+	"blog/_layout.tpl.html":   `{{.Title}}`,
+	"blog/index.md":           "# Blog\n\n> 01-03-2016\n\nBlog\n",
+	"blog/first.md":           "# First\n\n> 01-03-2016\n> author: thanh\n\nFirst\n",
+	"blog/second.md":          "# Second\n\n> 02-03-2016\n> author: thanh, huy\n\nSecond\n",
+	"blog/without-author.md":  "# Without\n\n> 04-03-2016\n\nWithout\n",
+}
+
+func TestLoadAuthors(T *testing.T) {
+	dir := writeFiles(T, authorFiles)
+	defer os.RemoveAll(dir)
+
+	authors, err := loadAuthors(filepath.Join(dir, "_authors"))
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(authors) != 2 {
+		T.Fatal("Expect 2 authors, got", authors)
+	}
+	thanh := authors["thanh"]
+	if thanh.ID != "thanh" || thanh.Name != "Thanh Tran" || thanh.Bio != "Backend" || thanh.Links["github"] != "https://github.com/thanh" {
+		T.Error("Expect profile loaded, got", thanh)
+	}
+	if huy := authors["huy"]; huy.Name != "huy" {
+		T.Error("Expect id as default name, got", huy.Name)
+	}
+
+	authors, err = loadAuthors(filepath.Join(dir, "_nope"))
+	if err != nil || len(authors) != 0 {
+		T.Error("Expect no authors without dir, got", authors, err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "_authors", "bad.json"), []byte("{"), 0644)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if _, err := loadAuthors(filepath.Join(dir, "_authors")); err == nil {
+		T.Error("Expect invalid profile rejected")
+	}
+}
+
+func TestAuthorPages(T *testing.T) {
+	dir := writeFiles(T, authorFiles)
+	defer os.RemoveAll(dir)
+
+	data, errs := loadDir(T, dir)
+	if errs != nil {
+		T.Fatal(errs)
+	}
+
+	thanh := data.Authors["thanh"]
+	if len(thanh.SortedArticles) != 2 || thanh.SortedArticles[0].Title != "First" || thanh.SortedArticles[1].Title != "Second" {
+		T.Error("Expect articles of author linked by date, got", thanh.SortedArticles)
+	}
+	if huy := data.Authors["huy"]; len(huy.SortedArticles) != 1 {
+		T.Error("Expect articles of second author linked, got", huy.SortedArticles)
+	}
+
+	entry := data.Entries["authors/thanh"]
+	if entry == nil || !entry.IsGenerated || entry.Data != thanh {
+		T.Fatal("Expect generated author page, got", entry)
+	}
+	if thanh.Path != "authors/thanh/" || entry.Article.Title != "Thanh Tran" || !entry.Article.Date.Equal(thanh.SortedArticles[1].Date) {
+		T.Error("Expect author page article, got", thanh.Path, entry.Article)
+	}
+	buf := &bytes.Buffer{}
+	if err := entry.Layout.Execute(buf, entry.TemplateData()); err != nil || buf.String() != "Thanh Tran: First Second" {
+		T.Error("Expect author page rendered, got", buf.String(), err)
+	}
+
+	// unknown authors are errors
+	err := ioutil.WriteFile(filepath.Join(dir, "blog", "unknown.md"), []byte("# Unknown\n\n> 03-03-2016\n> author: nobody\n\nUnknown\n"), 0644)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if _, errs := loadDir(T, dir); len(errs) != 1 || errs[0].Phase != "author" {
+		T.Error("Expect unknown author reported, got", errs)
+	}
+}
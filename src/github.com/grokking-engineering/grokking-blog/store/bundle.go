@@ -0,0 +1,109 @@
+package store
+
+import (
+	"html/template"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var reURLAttr = regexp.MustCompile(`(\s(?:src|href)=")([^"]*)(")`)
+
+// isAsset reports whether a non-markdown file inside content dir should be
+// served. Templates and files or directories starting with "_" or "." are
+// private, e.g. "_drafts/a.png" or ".git/config".
+func isAsset(relativePath string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(relativePath), "/") {
+		if strings.HasPrefix(part, "_") || strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return !strings.HasSuffix(relativePath, ".tpl.html")
+}
+
+// dirURL returns the absolute url of a content directory, with trailing
+// slash.
+func dirURL(dirPath string) string {
+	if dirPath == "." {
+		return "/"
+	}
+	return "/" + filepath.ToSlash(dirPath) + "/"
+}
+
+// resolveRelativeURLs rewrites relative src and href attributes against
+// baseURL, so content rendered outside of the article page (e.g. in a list)
+// still points to the right files.
+func resolveRelativeURLs(html template.HTML, baseURL string) template.HTML {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return html
+	}
+
+	result := reURLAttr.ReplaceAllStringFunc(string(html), func(attr string) string {
+		m := reURLAttr.FindStringSubmatch(attr)
+
+		// attribute values are html escaped
+		unescaped := strings.Replace(m[2], "&amp;", "&", -1)
+		resolved := resolveRelativeURL(base, unescaped)
+		return m[1] + strings.Replace(resolved, "&", "&amp;", -1) + m[3]
+	})
+	return template.HTML(result)
+}
+
+// resolveRelativeURL resolves link against base, other links are returned
+// unchanged.
+func resolveRelativeURL(base *url.URL, link string) string {
+	if !isRelativeURL(link) {
+		return link
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func isRelativeURL(link string) bool {
+	if link == "" || strings.HasPrefix(link, "/") ||
+		strings.HasPrefix(link, "#") || strings.HasPrefix(link, "?") {
+		return false
+	}
+
+	u, err := url.Parse(link)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// markBundles finds page bundles: directories with assets next to index.md,
+// and without subdirectories or other articles. Bundles are listed in their
+// parent directory like a normal article. Sections with only index.md, e.g.
+// "community/", are not bundles.
+func markBundles(data *Data) {
+	hasSubDirs := make(map[string]bool)
+	for dirPath := range data.Dirs {
+		if dirPath != "." {
+			hasSubDirs[filepath.Dir(dirPath)] = true
+		}
+	}
+	hasAssets := make(map[string]bool)
+	for assetPath := range data.Assets {
+		hasAssets[filepath.Dir(filepath.FromSlash(assetPath))] = true
+	}
+
+	for dirPath, dir := range data.Dirs {
+		if dirPath == "." || hasSubDirs[dirPath] || !hasAssets[dirPath] || len(dir.Entries) > 0 {
+			continue
+		}
+		entry := data.Entries[dirPath]
+		if entry == nil {
+			continue
+		}
+		parent := data.Dirs[filepath.Dir(dirPath)]
+		if parent == nil {
+			continue
+		}
+
+		entry.IsBundle = true
+		parent.Entries[filepath.Join(dirPath, "index.md")] = entry
+	}
+}
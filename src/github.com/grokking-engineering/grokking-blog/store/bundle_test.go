@@ -0,0 +1,116 @@
+package store
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsAsset(T *testing.T) {
+	tests := map[string]bool{
+		"blog/post/photo.png":      true,
+		"photo.png":                true,
+		"blog/_layout.tpl.html":    false,
+		"blog/post.tpl.html":       false,
+		".git/config":              false,
+		"blog/.DS_Store":           false,
+		"_drafts/x.png":            false,
+		"blog/_private/secret.txt": false,
+	}
+	for path, expected := range tests {
+		if isAsset(filepath.FromSlash(path)) != expected {
+			T.Errorf("Expect isAsset(%q) %v", path, expected)
+		}
+	}
+}
+
+func TestResolveRelativeURLs(T *testing.T) {
+	html := template.HTML(`<img src="photo.png"> <a href="../other?a=1&amp;b=2">x</a> ` +
+		`<a href="/abs">y</a> <a href="#top">z</a> <a href="https://grokking.org/">w</a>`)
+	expected := template.HTML(`<img src="/blog/post/photo.png"> <a href="/blog/other?a=1&amp;b=2">x</a> ` +
+		`<a href="/abs">y</a> <a href="#top">z</a> <a href="https://grokking.org/">w</a>`)
+	if result := resolveRelativeURLs(html, "/blog/post/"); result != expected {
+		T.Errorf("Expect %v, got %v", expected, result)
+	}
+}
+
+func TestPageBundles(T *testing.T) {
+	files := map[string]string{
+		"blog/index.md":           "# Blog\n\n> 01-03-2016\n\nBlog\n",
+		"blog/post/index.md":      "# Post\n\n> 02-03-2016\n\n![Photo](photo.png)\n",
+		"blog/post/photo.png":     "png",
+		"community/index.md":      "# Community\n\n> 01-03-2016\n\nCommunity\n",
+		"_drafts/x.png":           "png",
+		".git/config":             "[core]",
+		".git/index.md":           "# Git\n\n> 01-03-2016\n\nGit\n",
+		"blog/post/_notes.txt":    "notes",
+		"blog/post/post.tpl.html": "{{.Title}}",
+	}
+	for name, content := range checkFiles {
+		files[name] = content
+	}
+	dir := writeFiles(T, files)
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir}
+	err := store.Reload()
+	if err != nil {
+		T.Fatal(err)
+	}
+	snapshot := store.Snapshot()
+
+	post := snapshot.GetEntry("blog/post")
+	if post == nil || !post.IsBundle || post.Article.Path != "blog/post/" {
+		T.Fatal("Expect page bundle, got", post)
+	}
+	if blog := snapshot.GetDir("blog"); len(blog.SortedArticles) != 1 || blog.SortedArticles[0] != post.Article {
+		T.Error("Expect bundle listed in parent directory, got", blog.SortedArticles)
+	}
+	if strings.TrimSpace(string(post.Article.HtmlContent)) != `<p><img src="/blog/post/photo.png" alt="Photo" /></p>` {
+		T.Error("Expect relative links resolved, got", post.Article.HtmlContent)
+	}
+
+	// a section without assets is not a bundle
+	if community := snapshot.GetEntry("community"); community == nil || community.IsBundle {
+		T.Error("Expect community not a bundle, got", community)
+	}
+	for _, article := range snapshot.GetDir(".").SortedArticles {
+		if article.Title == "Community" {
+			T.Error("Expect community not listed in root")
+		}
+	}
+	if root := snapshot.GetEntry("."); root.Article.Path != "" {
+		T.Errorf("Expect root served at /, got %q", root.Article.Path)
+	}
+
+	if snapshot.GetAsset("blog/post/photo.png") != filepath.Join(dir, "blog", "post", "photo.png") {
+		T.Error("Expect asset found")
+	}
+	for _, path := range []string{".git/config", "_drafts/x.png", "blog/post/_notes.txt", "blog/post/post.tpl.html"} {
+		if snapshot.GetAsset(path) != "" {
+			T.Error("Expect private file not served:", path)
+		}
+	}
+	if snapshot.GetEntry(".git") != nil {
+		T.Error("Expect articles in hidden directories not loaded")
+	}
+}
+
+func TestInheritLayoutMissing(T *testing.T) {
+	dir := writeFiles(T, map[string]string{
+		"_layout_main.tpl.html": `{{.Content}}`,
+		"index.tpl.html":        `{{.Title}}`,
+		"index.md":              "# Home\n\n> 01-03-2016\n\nHome\n",
+		"blog/a.md":             "# A\n\n> 01-03-2016\n\nA\n",
+	})
+	defer os.RemoveAll(dir)
+
+	// used to loop forever on the root dir without layout
+	store := &Instance{ContentDir: dir}
+	errs, ok := store.Reload().(LoadErrors)
+	if !ok || len(errs) != 1 || errs[0].File != filepath.Join("blog", "a.md") || errs[0].Phase != "layout" {
+		T.Error("Expect missing layout reported, got", errs)
+	}
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// articleCache keeps parsed articles between reloads, so only changed .md
+// files are parsed again. It is only used by one reload at a time.
+type articleCache struct {
+	articles map[string]*cachedArticle
+
+	// articles are parsed again when shortcodes change
+	shortcodes string
+
+	// by language
+	related map[string]*cachedRelated
+
+	hits, misses int
+}
+
+type cachedRelated struct {
+	// paths of articles and weights
+	key     string
+	related map[template.URL][]template.URL
+}
+
+type cachedArticle struct {
+	modTime time.Time
+	size    int64
+
+	// output of parseArticleFile, never modified
+	article *Article
+
+	// seen in the current reload, others are removed by end()
+	seen bool
+}
+
+func newArticleCache() *articleCache {
+	return &articleCache{
+		articles: make(map[string]*cachedArticle),
+		related:  make(map[string]*cachedRelated),
+	}
+}
+
+// begin starts a reload. shortcodes is the signature of "_shortcodes".
+func (c *articleCache) begin(shortcodes string) {
+	if shortcodes != c.shortcodes {
+		c.articles = make(map[string]*cachedArticle)
+		c.related = make(map[string]*cachedRelated)
+		c.shortcodes = shortcodes
+	}
+	for _, cached := range c.articles {
+		cached.seen = false
+	}
+	c.hits, c.misses = 0, 0
+}
+
+// end removes articles of deleted files.
+func (c *articleCache) end() {
+	for path, cached := range c.articles {
+		if !cached.seen {
+			delete(c.articles, path)
+		}
+	}
+}
+
+// get returns a copy of the cached article if the file is not changed, or
+// nil. A nil cache is always empty.
+func (c *articleCache) get(path string, info os.FileInfo) *Article {
+	if c == nil {
+		return nil
+	}
+	cached := c.articles[path]
+	if cached == nil || !cached.modTime.Equal(info.ModTime()) || cached.size != info.Size() {
+		c.misses++
+		return nil
+	}
+	c.hits++
+	cached.seen = true
+	return cached.article.clone()
+}
+
+// put keeps a copy of article, before it is modified by loadFiles.
+func (c *articleCache) put(path string, info os.FileInfo, article *Article) {
+	if c == nil {
+		return
+	}
+	// computed once, shared by all copies
+	article.countTerms()
+	c.articles[path] = &cachedArticle{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		article: article.clone(),
+		seen:    true,
+	}
+}
+
+// buildRelated reuses related articles of the last reload of lang when no
+// article is changed, they are expensive to compute for a large archive.
+func (c *articleCache) buildRelated(lang string, articles []*Article, weights RelatedWeights, changed bool) {
+	if c == nil {
+		buildRelated(articles, weights)
+		return
+	}
+
+	paths := make([]string, len(articles)+1)
+	paths[0] = fmt.Sprint(weights)
+	for i, article := range articles {
+		paths[i+1] = string(article.Path)
+	}
+	key := strings.Join(paths, "\n")
+
+	cached := c.related[lang]
+	if changed || cached == nil || cached.key != key {
+		buildRelated(articles, weights)
+		cached = &cachedRelated{key: key, related: make(map[template.URL][]template.URL)}
+		for _, article := range articles {
+			for _, related := range article.related {
+				cached.related[article.Path] = append(cached.related[article.Path], related.Path)
+			}
+		}
+		c.related[lang] = cached
+		return
+	}
+
+	byPath := make(map[template.URL]*Article, len(articles))
+	for _, article := range articles {
+		byPath[article.Path] = article
+	}
+	for _, article := range articles {
+		paths := cached.related[article.Path]
+		article.related = make([]*Article, len(paths))
+		for k, path := range paths {
+			article.related[k] = byPath[path]
+		}
+	}
+}
+
+// clone copies fields which are modified after parsing, so articles of a
+// published snapshot are never modified by the next reload.
+func (a *Article) clone() *Article {
+	c := *a
+	c.Tags = append([]string(nil), a.Tags...)
+	c.Authors = append([]string(nil), a.Authors...)
+	return &c
+}
+
+// dirSignature changes when a file in dirPath is added, removed or
+// modified.
+func dirSignature(dirPath string) string {
+	files, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		return ""
+	}
+	sig := ""
+	for _, file := range files {
+		sig += fmt.Sprintf("%v %v %v;", file.Name(), file.ModTime().UnixNano(), file.Size())
+	}
+	return sig
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadIncremental(T *testing.T) {
+	dir := writeFiles(T, checkFiles)
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir}
+	if !store.LoadedAt().IsZero() {
+		T.Error("Expect zero time before first reload")
+	}
+	err := store.Reload()
+	if err != nil {
+		T.Fatal(err)
+	}
+	old := store.Snapshot()
+	oldA := old.GetEntry("a").Article
+
+	// rewrite b.md with a different size, so it is changed even when
+	// mtime has low resolution
+	err = ioutil.WriteFile(filepath.Join(dir, "b.md"), []byte("# B2\n\n> 02-03-2016 #go\n\nB, changed\n"), 0644)
+	if err != nil {
+		T.Fatal(err)
+	}
+	err = store.Reload()
+	if err != nil {
+		T.Fatal(err)
+	}
+	if store.cache.misses != 1 || store.cache.hits != 2 {
+		T.Errorf("Expect 1 parsed and 2 cached, got %v and %v", store.cache.misses, store.cache.hits)
+	}
+
+	snapshot := store.Snapshot()
+	if snapshot == old {
+		T.Fatal("Expect new snapshot")
+	}
+	if title := snapshot.GetEntry("b").Article.Title; title != "B2" {
+		T.Error("Expect changed article, got", title)
+	}
+	if snapshot.GetEntry("a").Article == oldA {
+		T.Error("Expect cached article copied")
+	}
+	if oldA.Next == nil || oldA.Next.Title != "Same" {
+		T.Error("Expect old snapshot not modified")
+	}
+
+	// related articles are reused, pointing to articles of the new snapshot
+	store.Reload()
+	newA := store.Snapshot().GetEntry("a").Article
+	related := newA.Related(1)
+	if len(related) != 1 || related[0] != store.Snapshot().GetEntry("b").Article {
+		T.Error("Expect cached related articles in new snapshot, got", related)
+	}
+
+	// deleted files are removed from cache
+	os.Remove(filepath.Join(dir, "b.md"))
+	store.Reload()
+	if store.Snapshot().GetEntry("b") != nil || len(store.cache.articles) != 2 {
+		T.Error("Expect deleted article removed")
+	}
+}
+
+var words = strings.Fields(`distributed system database cache queue index
+	replica shard leader follower consensus raft paxos latency throughput
+	golang rust python compiler runtime memory garbage collector thread
+	kernel network packet socket protocol http server client request`)
+
+func TestReloadConcurrent(T *testing.T) {
+	dir := writeFiles(T, checkFiles)
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir}
+	store.Init()
+
+	done := make(chan bool)
+	for i := 0; i < 2; i++ {
+		go func() {
+			for k := 0; k < 10; k++ {
+				store.Reload()
+			}
+			done <- true
+		}()
+	}
+	go func() {
+		for k := 0; k < 100; k++ {
+			for _, entry := range store.GetEntries() {
+				entry.Article.Related(5)
+				entry.Layout.Execute(ioutil.Discard, entry.TemplateData())
+			}
+		}
+		done <- true
+	}()
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+}
+
+// writeArchive writes n articles in dirs of 100 articles.
+func writeArchive(B *testing.B, n int) string {
+	files := map[string]string{
+		"_layout_main.tpl.html": `{{.Content}}`,
+		"_layout.tpl.html":      `{{.Title}}`,
+		"index.tpl.html":        `{{.Title}}`,
+		"index.md":              "# Home\n\n> 01-01-2016\n\nHome\n",
+	}
+	r := rand.New(rand.NewSource(1))
+	date := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		var content []string
+		for j := 0; j < 300; j++ {
+			content = append(content, words[r.Intn(len(words))])
+		}
+		name := fmt.Sprintf("dir%v/post%v.md", i/100, i)
+		files[name] = fmt.Sprintf("# Post %v\n\n> %v #%v #%v\n>\n> Short %v\n\n%v\n",
+			i, date.AddDate(0, 0, i).Format(kTimeFormat),
+			words[r.Intn(len(words))], words[r.Intn(len(words))], i,
+			strings.Join(content, " "))
+	}
+
+	dir, err := ioutil.TempDir("", "grokking-bench")
+	if err != nil {
+		B.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(content), 0644)
+	}
+	return dir
+}
+
+func benchmarkReload(B *testing.B, mode string) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeArchive(B, 5000)
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir}
+	err := store.Reload()
+	if err != nil {
+		B.Fatal(err)
+	}
+
+	changed := filepath.Join(dir, "dir0", "post0.md")
+	B.ResetTimer()
+	for i := 0; i < B.N; i++ {
+		switch mode {
+		case "full":
+			err = store.ClearCacheAndReload()
+		case "changed":
+			now := time.Now().Add(time.Duration(i) * time.Second)
+			os.Chtimes(changed, now, now)
+			err = store.Reload()
+		default:
+			err = store.Reload()
+		}
+		if err != nil {
+			B.Fatal(err)
+		}
+	}
+}
+
+// 5,000 articles, parsing everything
+func BenchmarkReloadFull(B *testing.B) {
+	benchmarkReload(B, "full")
+}
+
+// 5,000 articles, one changed
+func BenchmarkReloadChanged(B *testing.B) {
+	benchmarkReload(B, "changed")
+}
+
+// 5,000 articles, none changed like most reloads in development mode
+func BenchmarkReloadUnchanged(B *testing.B) {
+	benchmarkReload(B, "unchanged")
+}
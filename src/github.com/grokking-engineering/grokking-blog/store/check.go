@@ -0,0 +1,228 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Problem is found by Check.
+type Problem struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+func (p *Problem) String() string {
+	level := "error"
+	if p.Warning {
+		level = "warning"
+	}
+	location := p.File
+	if p.Line > 0 {
+		location = fmt.Sprintf("%v:%v", p.File, p.Line)
+	}
+	if location != "" {
+		location += ": "
+	}
+	return fmt.Sprintf("%v: %v[%v] %v", level, location, p.Kind, p.Message)
+}
+
+type problemByFile []*Problem
+
+func (a problemByFile) Len() int      { return len(a) }
+func (a problemByFile) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a problemByFile) Less(i, j int) bool {
+	if a[i].File != a[j].File {
+		return a[i].File < a[j].File
+	}
+	return a[i].Line < a[j].Line
+}
+
+var reLocalURL = regexp.MustCompile(`\s(src|href)="(/[^/"][^"]*|/)"`)
+
+// Check loads content and reports all problems found: invalid articles,
+// missing layouts, tags not in "_tags.txt", broken links and images,
+// duplicated titles and slugs, and template errors. Links to "/static/" are
+// checked against staticDir. Content is loaded in lenient mode, so entries
+// which loaded are still checked when others are invalid.
+func (this *Instance) Check(staticDir string) []*Problem {
+	var problems []*Problem
+	add := func(p *Problem) {
+		problems = append(problems, p)
+	}
+
+	lenient := this.Lenient
+	this.Lenient = true
+	err := this.Reload()
+	this.Lenient = lenient
+	for _, loadErr := range this.Errors() {
+		add(&Problem{
+			File:    loadErr.File,
+			Line:    loadErr.Line,
+			Kind:    loadErr.Phase,
+			Message: loadErr.Err.Error(),
+		})
+	}
+	if err != nil {
+		// nothing loaded, e.g. the main layout is invalid
+		sort.Stable(problemByFile(problems))
+		return problems
+	}
+
+	tags, err := readTags(filepath.Join(this.ContentDir, "_tags.txt"))
+	if err != nil && !os.IsNotExist(err) {
+		add(&Problem{File: "_tags.txt", Kind: "tag", Message: err.Error()})
+	}
+
+	entries := this.GetEntries()
+	var paths []string
+	for entryPath := range entries {
+		paths = append(paths, entryPath)
+	}
+	sort.Strings(paths)
+
+	exists := func(urlPath string) bool {
+		switch {
+		case strings.HasPrefix(urlPath, "/static/"):
+			if _, ok := this.Snapshot().Static().Lookup(urlPath[len("/static/"):]); ok {
+				return true
+			}
+			_, err := os.Stat(filepath.Join(staticDir, filepath.FromSlash(path.Clean(urlPath[len("/static/"):]))))
+			return err == nil
+		case strings.HasPrefix(urlPath, "/__"):
+			// generated by the server
+			return true
+		case urlPath == "/sitemap.xml" || urlPath == "/robots.txt":
+			return true
+		}
+		entryPath := strings.Trim(urlPath, "/")
+		if entryPath == "" {
+			entryPath = "."
+		}
+		return this.GetEntry(entryPath) != nil || this.GetAsset(entryPath) != ""
+	}
+
+	titles := make(map[string][]string)
+	slugs := make(map[string][]string)
+	for _, entryPath := range paths {
+		entry := entries[entryPath]
+		if entry.IsGenerated {
+			continue
+		}
+		article := entry.Article
+		file := entry.File
+
+		// vocabulary
+		for _, tag := range article.Tags {
+			if tags != nil && !tags[tag] {
+				add(&Problem{File: file, Line: this.findLine(file, tag), Kind: "tag", Message: fmt.Sprintf("Unknown tag %q", tag)})
+			}
+		}
+
+		// links and images
+		for _, m := range reLocalURL.FindAllStringSubmatch(string(article.HtmlContent), -1) {
+			urlPath := strings.Replace(m[2], "&amp;", "&", -1)
+			if i := strings.IndexAny(urlPath, "?#"); i >= 0 {
+				urlPath = urlPath[:i]
+			}
+			if exists(urlPath) {
+				continue
+			}
+
+			kind, message := "link", "Broken link"
+			if m[1] == "src" {
+				kind, message = "image", "Missing image"
+			}
+			add(&Problem{
+				File:    file,
+				Line:    this.findLine(file, path.Base(urlPath)),
+				Kind:    kind,
+				Message: fmt.Sprintf("%v %v", message, urlPath),
+			})
+		}
+
+		// duplicates
+		titles[article.Lang+":"+article.Title] = append(titles[article.Lang+":"+article.Title], file)
+		if !entry.IsDir {
+			slug := article.Lang + ":" + filepath.Base(entryPath)
+			slugs[slug] = append(slugs[slug], file)
+		}
+
+		// templates
+		err := entry.Layout.Execute(ioutil.Discard, entry.TemplateData())
+		if err != nil {
+			add(&Problem{File: file, Kind: "template", Message: err.Error()})
+		}
+	}
+
+	for _, duplicates := range []struct {
+		kind  string
+		files map[string][]string
+	}{{"title", titles}, {"slug", slugs}} {
+		var keys []string
+		for key, files := range duplicates.files {
+			if len(files) > 1 {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			files := duplicates.files[key]
+			name := key[strings.Index(key, ":")+1:]
+			for _, file := range files {
+				add(&Problem{
+					File:    file,
+					Kind:    duplicates.kind,
+					Message: fmt.Sprintf("Duplicated %v %q in %v", duplicates.kind, name, strings.Join(files, ", ")),
+					Warning: true,
+				})
+			}
+		}
+	}
+
+	sort.Stable(problemByFile(problems))
+	return problems
+}
+
+// readTags reads allowed tags, one per line.
+func readTags(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tags := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		tag := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "#")
+		if tag != "" {
+			tags[tag] = true
+		}
+	}
+	return tags, scanner.Err()
+}
+
+// findLine returns the first line in file containing text, or 0.
+func (this *Instance) findLine(file string, text string) int {
+	data, err := ioutil.ReadFile(filepath.Join(this.ContentDir, file))
+	if err != nil {
+		return 0
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.Contains(line, text) {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var checkFiles = map[string]string{
+	"_layout_main.tpl.html": `{{.Content}}`,
+	"_layout.tpl.html":      `{{.Title}}`,
+	"index.tpl.html":        `{{.Title}}`,
+	"_tags.txt":             "go\n",
+	"index.md":              "# Home\n\n> 01-03-2016 #go\n\nHome\n",
+	"a.md":                  "# Same\n\n> 01-03-2016 #go #web\n\nA\n\n<a href=\"/b\">b</a> <a href=\"/nope\">x</a>\n",
+	"b.md":                  "# Same\n\n> 01-03-2016\n\nB\n",
+}
+
+func TestCheck(T *testing.T) {
+	dir := writeFiles(T, checkFiles)
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir}
+	problems := store.Check(dir)
+
+	expected := []string{
+		`warning: a.md: [title] Duplicated title "Same" in a.md, b.md`,
+		`error: a.md:3: [tag] Unknown tag "web"`,
+		`error: a.md:7: [link] Broken link /nope`,
+		`warning: b.md: [title] Duplicated title "Same" in a.md, b.md`,
+	}
+	if len(problems) != len(expected) {
+		T.Fatalf("Expect %v problems, got %v", len(expected), problems)
+	}
+	for i, p := range problems {
+		if p.String() != expected[i] {
+			T.Errorf("Expect %q, got %q", expected[i], p.String())
+		}
+	}
+}
+
+func TestCheckParseError(T *testing.T) {
+	files := map[string]string{"c.md": "# C\n\n> 01-03-2016\n> series-part: x\n\nC\n"}
+	for name, content := range checkFiles {
+		files[name] = content
+	}
+	dir := writeFiles(T, files)
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir}
+	problems := store.Check(dir)
+
+	// other articles are still checked
+	if len(problems) != 5 || problems[2].Kind != "link" {
+		T.Fatalf("Expect 5 problems, got %v", problems)
+	}
+	if p := problems[4]; p.File != "c.md" || p.Line != 4 || p.Kind != "parse" {
+		T.Error("Expect parse problem at c.md:4, got", p)
+	}
+	if store.Lenient {
+		T.Error("Expect lenient mode restored")
+	}
+
+	// nothing to check without the main layout
+	os.Remove(filepath.Join(dir, "_layout_main.tpl.html"))
+	if problems := store.Check(dir); len(problems) != 2 || problems[0].Kind != "layout" || problems[1].Kind != "parse" {
+		T.Error("Expect only load problems, got", problems)
+	}
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// LoadError is a problem found while loading content.
+type LoadError struct {
+	// Path relative to content dir, empty when not caused by a file
+	File string
+	// 0 when unknown
+	Line int
+	// What was being loaded: "layout", "shortcode", "i18n", "author",
+	// "read", "parse", "duplicate", "generate" or "static"
+	Phase string
+	Err   error
+}
+
+func (e *LoadError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location = fmt.Sprintf("%v:%v", e.File, e.Line)
+	}
+	if location == "" {
+		return fmt.Sprintf("%v: %v", e.Phase, e.Err)
+	}
+	return fmt.Sprintf("%v: %v: %v", location, e.Phase, e.Err)
+}
+
+// newLoadError makes file relative to rootDir and takes the line from
+// parse errors.
+func newLoadError(rootDir, file, phase string, err error) *LoadError {
+	if file != "" {
+		if rel, relErr := filepath.Rel(rootDir, file); relErr == nil {
+			file = rel
+		}
+	}
+
+	loadErr := &LoadError{File: file, Phase: phase, Err: err}
+	switch err := err.(type) {
+	case *ParseError:
+		loadErr.Line = err.Line
+		loadErr.Err = err.Err
+	case *ShortcodeError:
+		loadErr.Line = err.Line
+		loadErr.Phase = "shortcode"
+		loadErr.Err = fmt.Errorf("%q: %v", err.Name, err.Err)
+	}
+	return loadErr
+}
+
+// LoadErrors is returned by Reload, so one invalid article does
+// not hide the others.
+type LoadErrors []*LoadError
+
+func (e LoadErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return fmt.Sprintf("%v errors:\n%v", len(e), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLoadErrors(T *testing.T) {
+	files := map[string]string{
+		"c.md":               "# C\n\n> 01-03-2016\n> series-part: x\n\nC\n",
+		"d.md":               "# D\n\n> 2016\n\nD\n",
+		"e/index.md":         "# E\n\n> 01-03-2016\n\nE\n",
+		"e/_layout.tpl.html": "{{.Title",
+	}
+	for name, content := range checkFiles {
+		files[name] = content
+	}
+	dir := writeFiles(T, files)
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir}
+	err := store.ClearCacheAndReload()
+	errs, ok := err.(LoadErrors)
+	if !ok {
+		T.Fatalf("Expect LoadErrors, got %v", err)
+	}
+
+	expected := []struct {
+		file  string
+		line  int
+		phase string
+	}{
+		{"c.md", 4, "parse"},
+		{"d.md", 3, "parse"},
+		{"e/_layout.tpl.html", 0, "layout"},
+	}
+	if len(errs) != len(expected) {
+		T.Fatalf("Expect %v errors, got %v", len(expected), errs)
+	}
+	for i, e := range expected {
+		if errs[i].File != e.file || errs[i].Line != e.line || errs[i].Phase != e.phase {
+			T.Errorf("Expect %v:%v %v, got %v", e.file, e.line, e.phase, errs[i])
+		}
+	}
+	if store.IsLoaded() {
+		T.Error("Expect content not loaded")
+	}
+}
+
+func TestLoadLenient(T *testing.T) {
+	files := map[string]string{
+		"c.md": "# C\n\n> 01-03-2016\n> series-part: x\n\nC\n",
+		"d.md": "# D\n\n> 01-03-2016\n> author: nobody\n\nD\n",
+	}
+	for name, content := range checkFiles {
+		files[name] = content
+	}
+	dir := writeFiles(T, files)
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir, Lenient: true}
+	err := store.ClearCacheAndReload()
+	if err != nil {
+		T.Fatal("Expect content published, got", err)
+	}
+
+	if store.GetEntry("c") != nil {
+		T.Error("Expect invalid article skipped")
+	}
+	if entry := store.GetEntry("d"); entry == nil || len(entry.Article.Authors) != 0 {
+		T.Error("Expect article published without unknown author")
+	}
+	if store.GetEntry("a") == nil {
+		T.Error("Expect valid article published")
+	}
+
+	errs := store.Errors()
+	if len(errs) != 2 || errs[0].File != "c.md" || errs[1].Phase != "author" {
+		T.Error("Expect errors reported, got", errs)
+	}
+}
+
+func TestLoadLenientMainLayout(T *testing.T) {
+	files := map[string]string{}
+	for name, content := range checkFiles {
+		files[name] = content
+	}
+	files["_layout_main.tpl.html"] = "{{.Content"
+	dir := writeFiles(T, files)
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir, Lenient: true}
+	err := store.ClearCacheAndReload()
+	if err == nil || store.IsLoaded() {
+		T.Error("Expect content rejected without main layout")
+	}
+}
+
+func TestLoadLenientLayouts(T *testing.T) {
+	files := map[string]string{
+		"e/_layout.tpl.html": "{{.Title",
+		"e/index.md":         "# E\n\n> 01-03-2016\n\nE\n",
+		"e/post.md":          "# Post\n\n> 01-03-2016\n\nPost\n",
+		"e/sub/post.md":      "# Sub\n\n> 01-03-2016\n\nSub\n",
+		"f.md":               "# F\n\n> 01-03-2016\n\nF\n",
+		"f.tpl.html":         "{{.Title",
+	}
+	for name, content := range checkFiles {
+		files[name] = content
+	}
+	dir := writeFiles(T, files)
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir, Lenient: true}
+	err := store.ClearCacheAndReload()
+	if err != nil {
+		T.Fatal("Expect content published, got", err)
+	}
+
+	for _, path := range []string{"e/post", "e/sub/post", "f"} {
+		if store.GetEntry(path) != nil || store.GetEntries()[path] != nil {
+			T.Error("Expect article with invalid layout skipped:", path)
+		}
+	}
+	if len(store.GetDir("e").SortedArticles) != 0 || len(store.GetDir("e/sub").SortedArticles) != 0 {
+		T.Error("Expect skipped articles not listed")
+	}
+	for _, article := range store.GetDir(".").SortedArticles {
+		if article.Title == "F" {
+			T.Error("Expect skipped article not listed")
+		}
+	}
+	// e/index.md uses the layout of the root
+	if store.GetEntry("e") == nil || store.GetEntry("a") == nil {
+		T.Error("Expect valid articles published")
+	}
+
+	errs := store.Errors()
+	if len(errs) != 2 || errs[0].File != "e/_layout.tpl.html" || errs[1].File != "f.tpl.html" {
+		T.Error("Expect invalid layouts reported once, got", errs)
+	}
+}
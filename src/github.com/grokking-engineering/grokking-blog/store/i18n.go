@@ -0,0 +1,79 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// loadStrings reads "<lang>.json" in dirPath, falling back to strings of
+// the default language. Missing files are not an error.
+func loadStrings(dirPath string, languages []string, lang string) (map[string]string, error) {
+	strings := make(map[string]string)
+	if len(languages) == 0 {
+		return strings, nil
+	}
+
+	load := func(lang string) error {
+		path := filepath.Join(dirPath, lang+".json")
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(data, &strings)
+		if err != nil {
+			return fmt.Errorf("%v: %v", path, err)
+		}
+		return nil
+	}
+
+	if lang != languages[0] {
+		err := load(languages[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := load(lang)
+	if err != nil {
+		return nil, err
+	}
+	return strings, nil
+}
+
+// linkTranslations sets Translations of articles with the same path in
+// different languages. langs must be in the order of Languages.
+func linkTranslations(langs []*Data) {
+	groups := make(map[string][]*Article)
+	var keys []string
+	for _, data := range langs {
+		for _, entry := range data.Entries {
+			article := entry.Article
+			if entry.IsGenerated || article.translationKey == "" {
+				continue
+			}
+			if groups[article.translationKey] == nil {
+				keys = append(keys, article.translationKey)
+			}
+			groups[article.translationKey] = append(groups[article.translationKey], article)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		group := groups[key]
+		for _, article := range group {
+			article.Translations = nil
+			for _, other := range group {
+				if other != article {
+					article.Translations = append(article.Translations, other)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitLang(T *testing.T) {
+	opts := loadOptions{Languages: []string{"vi", "en"}}
+	tests := []struct {
+		input, name, lang string
+	}{
+		{"post", "post", "vi"},
+		{"post.en", "post", "en"},
+		{"post.vi", "post", "vi"},
+		{"post.fr", "post.fr", "vi"},
+		{"index.en", "index", "en"},
+		{"v1.2", "v1.2", "vi"},
+	}
+	for _, test := range tests {
+		name, lang := opts.splitLang(test.input)
+		if name != test.name || lang != test.lang {
+			T.Errorf("Expect %v %v for %v, got %v %v", test.name, test.lang, test.input, name, lang)
+		}
+	}
+
+	// single language, names are not split
+	if name, lang := (loadOptions{}).splitLang("post.en"); name != "post.en" || lang != "" {
+		T.Error("Expect name unchanged, got", name, lang)
+	}
+}
+
+func TestLinkTranslations(T *testing.T) {
+	viPost := &Article{Title: "vi", translationKey: "blog/post"}
+	enPost := &Article{Title: "en", translationKey: "blog/post"}
+	viOnly := &Article{Title: "vi only", translationKey: "blog/only"}
+	author := &Article{Title: "author"}
+
+	vi := &Data{Entries: map[string]*Entry{
+		"blog/post":    {Article: viPost},
+		"blog/only":    {Article: viOnly},
+		"authors/huy/": {Article: author, IsGenerated: true},
+	}}
+	en := &Data{Entries: map[string]*Entry{
+		"blog/post": {Article: enPost},
+	}}
+	linkTranslations([]*Data{vi, en})
+
+	if len(viPost.Translations) != 1 || viPost.Translations[0] != enPost {
+		T.Error("Expect en translation, got", viPost.Translations)
+	}
+	if len(enPost.Translations) != 1 || enPost.Translations[0] != viPost {
+		T.Error("Expect vi translation, got", enPost.Translations)
+	}
+	if len(viOnly.Translations) != 0 || len(author.Translations) != 0 {
+		T.Error("Expect no translations")
+	}
+}
+
+func TestLanguages(T *testing.T) {
+	dir := writeFiles(T, map[string]string{
+		"_layout_main.tpl.html": `{{.Content}}`,
+		"_layout.tpl.html":      `{{.Title}}`,
+		"_i18n/vi.json":         `{"read-more": "Xem thêm", "home": "Trang chủ"}`,
+		"_i18n/en.json":         `{"read-more": "Read more"}`,
+		"index.md":              "# Trang chủ\n\n> 01-03-2016\n\nHome\n",
+		"index.en.md":           "# Home\n\n> 01-03-2016\n\nHome\n",
+		"blog/_layout.tpl.html": `{{.Title}} {{T "read-more"}} {{T "home"}}`,
+		"blog/index.md":         "# Blog\n\n> 01-03-2016\n\nBlog\n",
+		"blog/post.md":          "# Bài viết\n\n> 01-03-2016\n\nVi\n",
+		"blog/post.en.md":       "# Post\n\n> 01-03-2016\n\nEn\n",
+		"blog/only-vi.md":       "# Chỉ tiếng Việt\n\n> 02-03-2016\n\nVi\n",
+	})
+	defer os.RemoveAll(dir)
+
+	store := &Instance{ContentDir: dir, Languages: []string{"vi", "en"}}
+	err := store.ClearCacheAndReload()
+	if err != nil {
+		T.Fatal(err)
+	}
+	snapshot := store.Snapshot()
+
+	tests := []struct {
+		path, title, rendered string
+	}{
+		{"blog/post", "Bài viết", "Bài viết Xem thêm Trang chủ"},
+		{"en/blog/post", "Post", "Post Read more Trang chủ"},
+		{"en", "Home", ""},
+		{"blog/only-vi", "Chỉ tiếng Việt", ""},
+	}
+	for _, test := range tests {
+		entry := snapshot.GetEntry(test.path)
+		if entry == nil || entry.Article.Title != test.title {
+			T.Errorf("Expect %v at %v, got %v", test.title, test.path, entry)
+			continue
+		}
+		if test.rendered == "" {
+			continue
+		}
+		buf := &bytes.Buffer{}
+		err := entry.Layout.Execute(buf, entry.TemplateData())
+		if err != nil || buf.String() != test.rendered {
+			T.Errorf("Expect %q at %v, got %q %v", test.rendered, test.path, buf.String(), err)
+		}
+	}
+	if snapshot.GetEntry("en/blog/only-vi") != nil {
+		T.Error("Expect untranslated article only in default language")
+	}
+
+	data, path := snapshot.dataFor("en/blog/post")
+	if data.Lang != "en" || path != filepath.Join("blog", "post") {
+		T.Error("Expect en data, got", data.Lang, path)
+	}
+	if data, path := snapshot.dataFor("en"); data.Lang != "en" || path != "." {
+		T.Error("Expect en root, got", data.Lang, path)
+	}
+	if data, path := snapshot.dataFor("blog/post"); data.Lang != "vi" || path != "blog/post" {
+		T.Error("Expect default language, got", data.Lang, path)
+	}
+	if post := snapshot.GetEntry("en/blog/post").Article; post.Path != "en/blog/post" || len(post.Translations) != 1 || post.Translations[0].Lang != "vi" {
+		T.Error("Expect translations linked, got", post.Path, post.Translations)
+	}
+	if home := snapshot.GetEntry("en").Article; home.Path != "en/" || len(home.Translations) != 1 || home.Translations[0].Lang != "vi" {
+		T.Error("Expect home pages linked, got", home.Path, home.Translations)
+	}
+	if len(snapshot.GetEntries()) != len(snapshot.data.Entries)+len(snapshot.data.Langs["en"].Entries) {
+		T.Error("Expect entries of all languages")
+	}
+}
@@ -5,19 +5,45 @@ import (
 	"html/template"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
+	"github.com/grokking-engineering/grokking-blog/images"
 	"github.com/grokking-engineering/grokking-blog/utils/logs"
 )
 
 type Data struct {
 	MainLayout *template.Template
 
+	// Language of this data, empty when Languages is not configured
+	Lang string
+	// "" for the default language, "<lang>/" for others
+	PathPrefix string
+	// Translation strings from _i18n/<lang>.json
+	Strings map[string]string
+
+	// Set on the default language only: data of other languages, and
+	// entries of all languages by url path.
+	Langs      map[string]*Data
+	AllEntries map[string]*Entry
+
 	Entries map[string]*Entry
 	Dirs    map[string]*Dir
 
+	Authors map[string]*Author
+	Series  map[string]*Series
+
+	// Listed articles by year and month, newest first
+	Archive []*ArchiveYear
+
+	// Files inside content dir which are neither articles nor templates,
+	// e.g. images in page bundles. Map from url path to file path.
+	Assets map[string]string
+
 	SortedArticles []*Article
 }
 
@@ -25,6 +51,38 @@ type Entry struct {
 	Article *Article
 	Layout  *template.Template
 	IsDir   bool
+
+	// index.md of a leaf directory, listed in its parent directory
+	IsBundle bool
+
+	// Pages like "authors/<id>" which have no .md file. Layout is executed
+	// with Data instead of Article.
+	IsGenerated bool
+	Data        interface{}
+
+	// Path of the .md file relative to content dir, empty for generated
+	// pages
+	File string
+
+	// Modification time of the .md file
+	ModTime time.Time
+}
+
+// LastModified returns the article date, or file modification time for
+// articles without date.
+func (e *Entry) LastModified() time.Time {
+	if e.Article != nil && !e.Article.Date.IsZero() {
+		return e.Article.Date
+	}
+	return e.ModTime
+}
+
+// TemplateData returns the value passed to entry layout.
+func (e *Entry) TemplateData() interface{} {
+	if e.Data != nil {
+		return e.Data
+	}
+	return e.Article
 }
 
 type Dir struct {
@@ -33,13 +91,98 @@ type Dir struct {
 	Entries map[string]*Entry
 
 	SortedArticles []*Article
+
+	// _layout.tpl.html exists but can not be parsed
+	invalidLayout bool
 }
 
-func loadFiles(rootDir string) (*Data, error) {
+type loadOptions struct {
+	// Languages of the site, the first one is the default. Articles in other
+	// languages are named like "post.en.md" and served at "/en/post". Only
+	// articles in Lang are loaded.
+	Languages []string
+	Lang      string
+
+	// Optional, adds responsive variants to images in articles.
+	Images *images.Processor
+
+	Related RelatedWeights
+
+	// Skip invalid entries instead of failing, see Instance.Lenient.
+	Lenient bool
+
+	// Optional, reuses articles parsed by previous reloads.
+	Cache *articleCache
+
+	// Optional, urls of the "asset" template function are not fingerprinted
+	// when nil.
+	Static *StaticManifest
+}
+
+// pathPrefix is prepended to urls of articles in opts.Lang.
+func (opts loadOptions) pathPrefix() string {
+	if len(opts.Languages) == 0 || opts.Lang == opts.Languages[0] {
+		return ""
+	}
+	return opts.Lang + "/"
+}
+
+// splitLang splits "post.en" into "post" and "en". Names without a known
+// language belong to the default language.
+func (opts loadOptions) splitLang(baseName string) (string, string) {
+	if len(opts.Languages) == 0 {
+		return baseName, ""
+	}
+	ext := filepath.Ext(baseName)
+	for _, lang := range opts.Languages {
+		if ext == "."+lang {
+			return baseName[:len(baseName)-len(ext)], lang
+		}
+	}
+	return baseName, opts.Languages[0]
+}
+
+// contentFile is a directory or file inside content dir.
+type contentFile struct {
+	path         string
+	relativePath string
+	info         os.FileInfo
+}
+
+// scanContent walks content dir once, for all languages. Directories like
+// _shortcodes or .git are not content and skipped.
+func scanContent(rootDir string) ([]contentFile, error) {
+	var files []contentFile
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if relativePath != "." && (strings.HasPrefix(info.Name(), "_") || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			log.Println("Load dir: ", relativePath)
+		}
+		files = append(files, contentFile{path, relativePath, info})
+		return nil
+	})
+	return files, err
+}
+
+// loadFiles loads files found by scanContent in opts.Lang.
+func loadFiles(rootDir string, files []contentFile, opts loadOptions) (*Data, LoadErrors) {
 
 	data := &Data{
-		Entries: make(map[string]*Entry),
-		Dirs:    make(map[string]*Dir),
+		Lang:       opts.Lang,
+		PathPrefix: opts.pathPrefix(),
+		Entries:    make(map[string]*Entry),
+		Dirs:       make(map[string]*Dir),
+		Assets:     make(map[string]string),
 	}
 
 	makeFuncMap := func(basePath string) template.FuncMap {
@@ -55,9 +198,59 @@ func loadFiles(rootDir string) (*Data, error) {
 				}
 				return dir.SortedArticles, nil
 			},
+			"image": func(imagePath string, width int) (*images.Image, error) {
+				if opts.Images == nil {
+					return nil, errors.New("Image processing is disabled")
+				}
+				if !strings.HasPrefix(imagePath, "/") {
+					relDirPath, err := filepath.Rel(rootDir, basePath)
+					if err != nil {
+						return nil, err
+					}
+					imagePath = dirURL(relDirPath) + imagePath
+				}
+				return opts.Images.Get(imagePath, width)
+			},
+			"related": func(article *Article, n int) []*Article {
+				return article.Related(n)
+			},
+			"archive": func() []*ArchiveYear {
+				return data.Archive
+			},
+			"author": func(id string) (*Author, error) {
+				author := data.Authors[id]
+				if author == nil {
+					return nil, errors.New("Author not exist: " + id)
+				}
+				return author, nil
+			},
+			"asset": func(name string) (string, error) {
+				return opts.Static.URL(name)
+			},
+			"T": func(key string) string {
+				if value, ok := data.Strings[key]; ok {
+					return value
+				}
+				return key
+			},
 		}
 	}
 
+	// number of articles not in cache
+	parsed := 0
+
+	// errors are collected, so all invalid files are reported at once
+	var errs LoadErrors
+	fail := func(file, phase string, err error) {
+		loadErr := newLoadError(rootDir, file, phase, err)
+		l.WithError(loadErr.Err).WithFields(logs.M{
+			"file":  loadErr.File,
+			"line":  loadErr.Line,
+			"phase": loadErr.Phase,
+		}).Error("Unable to load content")
+		errs = append(errs, loadErr)
+	}
+
 	parseFiles := func(path string) (*template.Template, error) {
 		tpl := template.New(filepath.Base(path))
 		tpl.Funcs(makeFuncMap(filepath.Dir(path)))
@@ -68,25 +261,36 @@ func loadFiles(rootDir string) (*Data, error) {
 	mainLayoutPath := filepath.Join(rootDir, "_layout_main.tpl.html")
 	tpl, err := parseFiles(mainLayoutPath)
 	if err != nil {
-		l.WithError(err).WithFields(logs.M{
-			"mainLayoutPath": mainLayoutPath,
-		}).Error("Unable to load main layout")
-		return nil, errors.New("Fatal")
+		fail(mainLayoutPath, "layout", err)
 	}
 	data.MainLayout = tpl
 
-	walkFunc := func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		relativePath, err := filepath.Rel(rootDir, path)
-		if err != nil {
-			return err
-		}
+	// load shortcodes
+	shortcodesPath := filepath.Join(rootDir, "_shortcodes")
+	shortcodes, err := loadShortcodes(shortcodesPath)
+	if err != nil {
+		fail(shortcodesPath, "shortcode", err)
+	}
+
+	// load translation strings
+	i18nPath := filepath.Join(rootDir, "_i18n")
+	data.Strings, err = loadStrings(i18nPath, opts.Languages, opts.Lang)
+	if err != nil {
+		fail(i18nPath, "i18n", err)
+	}
+
+	// load authors
+	authorsPath := filepath.Join(rootDir, "_authors")
+	data.Authors, err = loadAuthors(authorsPath)
+	if err != nil {
+		fail(authorsPath, "author", err)
+	}
+
+	visit := func(file contentFile) {
+		path, relativePath, info := file.path, file.relativePath, file.info
 
 		// load dir
 		if info.IsDir() {
-			log.Println("Load dir: ", relativePath)
 			dir := &Dir{Path: relativePath}
 			dir.Entries = make(map[string]*Entry)
 			data.Dirs[relativePath] = dir
@@ -95,78 +299,104 @@ func loadFiles(rootDir string) (*Data, error) {
 			_, err := os.Stat(layoutPath)
 			if err != nil {
 				// Skip reading template file
-				return nil
+				return
 			}
 
 			// load dir template
 			log.Println("Load tpl: ", layoutPath)
 			tpl, err := parseFiles(layoutPath)
 			if err != nil {
-				l.WithError(err).WithFields(logs.M{
-					"layoutPath": layoutPath,
-				}).Error("Unable to parse template!")
-				return errors.New("Fatal")
+				// entries inheriting this layout are removed in lenient mode
+				fail(layoutPath, "layout", err)
+				dir.invalidLayout = true
+				return
 			}
 
 			dir.Layout = tpl
-			return nil
+			return
 		}
 
 		ext := filepath.Ext(relativePath)
 		if ext != ".md" {
-			return nil
+			if isAsset(relativePath) {
+				data.Assets[filepath.ToSlash(relativePath)] = path
+			}
+			return
 		}
 		baseName := filepath.Base(relativePath)
 		baseName = baseName[:len(baseName)-len(ext)]
-		stripPath := relativePath[:len(relativePath)-len(ext)]
+		baseName, lang := opts.splitLang(baseName)
+		if lang != opts.Lang {
+			return
+		}
 		dirPath := filepath.Dir(relativePath)
+		stripPath := filepath.Join(dirPath, baseName)
 
 		log.Println("Load file:", relativePath)
-		entry := &Entry{}
+		entry := &Entry{File: relativePath, ModTime: info.ModTime()}
 
-		// save to entries, check for index.md
+		// check for index.md
+		entryPath := stripPath
 		if baseName == "index" {
-			data.Entries[dirPath] = entry
+			entryPath = dirPath
 			entry.IsDir = true
-		} else {
-			data.Entries[stripPath] = entry
 		}
 
 		// load article
-		bytes, err := ioutil.ReadFile(path)
-		if err != nil {
-			l.WithError(err).WithFields(logs.M{
-				"path": path,
-			}).Error("Unable to load .md file!")
-			return errors.New("Fatal")
-		}
+		article := opts.Cache.get(path, info)
+		if article == nil {
+			parsed++
+			bytes, err := ioutil.ReadFile(path)
+			if err != nil {
+				fail(path, "read", err)
+				return
+			}
 
-		article, err := parseArticle(string(bytes))
-		if err != nil {
-			l.WithError(err).WithFields(logs.M{
-				"path": path,
-			}).Error("Unable to parse .md file!")
-			return errors.New("Fatal")
+			article, err = parseArticleFile(path, string(bytes), shortcodes)
+			if err != nil {
+				fail(path, "parse", err)
+				return
+			}
+			article.HtmlContent = resolveRelativeURLs(article.HtmlContent, dirURL(dirPath))
+			article.Image = resolveRelativeURL(&url.URL{Path: dirURL(dirPath)}, article.Image)
+			opts.Cache.put(path, info, article)
 		}
 
-		article.Path = template.URL(stripPath)
+		if data.Entries[entryPath] != nil {
+			fail(path, "duplicate", errors.New("Duplicated article, check language suffix"))
+			return
+		}
+		data.Entries[entryPath] = entry
+
+		article.Lang = opts.Lang
+		article.translationKey = entryPath
+		article.Path = template.URL(data.PathPrefix + stripPath)
+		if entry.IsDir {
+			// "" for the root, so it is served at "/" and not "/./"
+			article.Path = template.URL(data.PathPrefix)
+			if dirPath != "." {
+				article.Path += template.URL(filepath.ToSlash(dirPath) + "/")
+			}
+		}
+		if opts.Images != nil {
+			article.HtmlContent = opts.Images.RewriteHTML(article.HtmlContent)
+		}
 		entry.Article = article
 
 		// load article template
 
 		layoutBaseName := baseName + ".tpl.html"
 		layoutPath := filepath.Join(filepath.Dir(path), layoutBaseName)
-		_, err = os.Stat(layoutPath)
+		_, err := os.Stat(layoutPath)
 		if err != nil {
 			log.Println("Skip tpl: ", layoutPath)
 		} else {
 			log.Println("Load tpl: ", layoutPath)
 			tpl, err := parseFiles(layoutPath)
 			if err != nil {
-				l.WithError(err).WithFields(logs.M{
-					"path": layoutPath,
-				}).Error("Unable to parse template!")
-				return errors.New("Fatal")
+				fail(layoutPath, "layout", err)
+				delete(data.Entries, entryPath)
+				return
 			}
 
 			entry.Layout = tpl
@@ -179,55 +409,112 @@ func loadFiles(rootDir string) (*Data, error) {
 				dir.Entries[relativePath] = entry
 			}
 		} else {
-			l.WithFields(logs.M{
-				"dirPath":      dirPath,
-				"relativePath": relativePath,
-			}).Error("Something is wrong: dirPath not exist!")
-			return errors.New("Fatal")
+			fail(path, "read", errors.New("Something is wrong: dirPath not exist!"))
 		}
-
-		return nil
 	}
 
-	err = filepath.Walk(rootDir, walkFunc)
-	if err != nil {
-		return nil, err
+	for _, file := range files {
+		visit(file)
 	}
 
+	markBundles(data)
+
 	// clean up
 	for path, entry := range data.Entries {
 		if entry.Layout == nil {
-			entry.Layout = inheritLayout(data, path)
-			if entry.Layout == nil {
-				l.WithFields(logs.M{
-					"path": path,
-				}).Error("No template found for article")
-				return nil, errors.New("Fatal")
+			layout, valid := inheritLayout(data, path)
+			entry.Layout = layout
+			if layout == nil {
+				// invalid layouts are already reported
+				if valid {
+					fail(filepath.Join(rootDir, entry.File), "layout", errors.New("No template found for article"))
+				}
+				delete(data.Entries, path)
+				// bundles are also listed in the parent directory
+				for _, dirPath := range []string{filepath.Dir(entry.File), filepath.Dir(path)} {
+					if dir := data.Dirs[dirPath]; dir != nil {
+						delete(dir.Entries, entry.File)
+					}
+				}
 			}
 		}
 	}
 
+	// nothing can be rendered without main layout
+	if len(errs) > 0 && (!opts.Lenient || data.MainLayout == nil) {
+		return nil, errs
+	}
+
 	data.SortedArticles = getSortedArticles(data.Entries)
 	for _, dir := range data.Dirs {
 		dir.SortedArticles = getSortedArticles(dir.Entries)
+		sort.Stable(articleByOrder(dir.SortedArticles))
+		linkNeighbors(dir.SortedArticles)
+	}
+
+	listedArticles := getListedArticles(data)
+	data.Series = buildSeries(data.SortedArticles)
+	data.Archive = buildArchive(getSortedListedArticles(data, listedArticles), data.PathPrefix)
+	opts.Cache.buildRelated(opts.Lang, listedArticles, opts.Related, parsed > 0)
+
+	for _, err := range linkAuthors(data) {
+		fail("", "author", err)
+	}
+	if len(errs) > 0 && !opts.Lenient {
+		return nil, errs
 	}
 
-	return data, nil
+	// generate pages
+	generators := []struct {
+		layoutName string
+		add        func(*Data, *template.Template) error
+	}{
+		{"_layout_author.tpl.html", addAuthorPages},
+		{"_layout_archive.tpl.html", addArchivePages},
+	}
+	for _, g := range generators {
+		layoutPath := filepath.Join(rootDir, g.layoutName)
+		if _, err := os.Stat(layoutPath); err != nil {
+			continue
+		}
+
+		tpl, err := parseFiles(layoutPath)
+		if err != nil {
+			fail(layoutPath, "layout", err)
+			continue
+		}
+
+		err = g.add(data, tpl)
+		if err != nil {
+			fail(layoutPath, "generate", err)
+		}
+	}
+	if len(errs) > 0 && !opts.Lenient {
+		return nil, errs
+	}
+
+	return data, errs
 }
 
-func inheritLayout(data *Data, path string) *template.Template {
+// inheritLayout returns the layout of the closest directory which has one,
+// and false when that layout is invalid.
+func inheritLayout(data *Data, path string) (*template.Template, bool) {
 	for {
 		dirPath := filepath.Dir(path)
 		dir := data.Dirs[dirPath]
 		if dir == nil {
-			return nil
+			return nil, true
+		}
+		if dir.invalidLayout {
+			return nil, false
 		}
 		if dir.Layout != nil {
-			return dir.Layout
+			return dir.Layout, true
 		}
 		if dirPath == "." {
-			return nil
+			return nil, true
 		}
+		path = dirPath
 	}
 }
 
@@ -236,9 +523,73 @@ type articleByDate []*Article
 func (a articleByDate) Len() int      { return len(a) }
 func (a articleByDate) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 func (a articleByDate) Less(i, j int) bool {
+	if a[i].Date.Equal(a[j].Date) {
+		// entries come from a map, keep the order stable
+		return a[i].Path < a[j].Path
+	}
 	return a[i].Date.Sub(a[j].Date) < 0
 }
 
+// articleByOrder sorts articles with "order:" first, keeping the date order
+// of the others.
+type articleByOrder []*Article
+
+func (a articleByOrder) Len() int      { return len(a) }
+func (a articleByOrder) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a articleByOrder) Less(i, j int) bool {
+	if a[i].order == 0 || a[j].order == 0 {
+		return a[j].order == 0 && a[i].order != 0
+	}
+	return a[i].order < a[j].order
+}
+
+// getListedArticles returns articles which are listed in a directory,
+// sorted by path.
+func getListedArticles(data *Data) []*Article {
+	var paths []string
+	for path, entry := range data.Entries {
+		if !entry.IsDir || entry.IsBundle {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	articles := make([]*Article, len(paths))
+	for i, path := range paths {
+		articles[i] = data.Entries[path].Article
+	}
+	return articles
+}
+
+// getSortedListedArticles filters data.SortedArticles, keeping the date
+// order.
+func getSortedListedArticles(data *Data, listedArticles []*Article) []*Article {
+	listed := make(map[*Article]bool)
+	for _, article := range listedArticles {
+		listed[article] = true
+	}
+
+	var articles []*Article
+	for _, article := range data.SortedArticles {
+		if listed[article] {
+			articles = append(articles, article)
+		}
+	}
+	return articles
+}
+
+// linkNeighbors sets Prev and Next of each article in a directory listing.
+func linkNeighbors(sortedArticles []*Article) {
+	for i, article := range sortedArticles {
+		if i > 0 {
+			article.Prev = sortedArticles[i-1]
+		}
+		if i < len(sortedArticles)-1 {
+			article.Next = sortedArticles[i+1]
+		}
+	}
+}
+
 func getSortedArticles(entries map[string]*Entry) []*Article {
 	var a []*Article
 	for _, entry := range entries {
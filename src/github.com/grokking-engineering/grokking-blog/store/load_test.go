@@ -0,0 +1,80 @@
+package store
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+var neighborFiles = map[string]string{
+	"blog/_layout.tpl.html": `{{.Title}}`,
+	"blog/index.md":         "# Blog\n\n> 01-03-2016\n\nBlog\n",
+	"blog/first.md":         "# First\n\n> 01-03-2016\n\nFirst\n",
+	"blog/second.md":        "# Second\n\n> 03-03-2016\n\nSecond\n",
+}
+
+func loadNeighbors(T *testing.T, files map[string]string) (*Snapshot, func()) {
+	all := map[string]string{}
+	for name, content := range checkFiles {
+		all[name] = content
+	}
+	for name, content := range files {
+		all[name] = content
+	}
+	dir := writeFiles(T, all)
+
+	store := &Instance{ContentDir: dir}
+	err := store.Reload()
+	if err != nil {
+		os.RemoveAll(dir)
+		T.Fatal(err)
+	}
+	return store.Snapshot(), func() { os.RemoveAll(dir) }
+}
+
+func TestLinkNeighbors(T *testing.T) {
+	snapshot, cleanup := loadNeighbors(T, neighborFiles)
+	defer cleanup()
+
+	blog := snapshot.GetDir("blog")
+	first := snapshot.GetEntry("blog/first").Article
+	second := snapshot.GetEntry("blog/second").Article
+	if len(blog.SortedArticles) != 2 || blog.SortedArticles[0] != first || blog.SortedArticles[1] != second {
+		T.Fatal("Expect articles by date, got", titles(blog.SortedArticles))
+	}
+	if first.Prev != nil || first.Next != second || second.Prev != first || second.Next != nil {
+		T.Error("Expect neighbors in the directory")
+	}
+	if index := snapshot.GetEntry("blog").Article; index.Prev != nil || index.Next != nil {
+		T.Error("Expect index not linked")
+	}
+	if root := snapshot.GetEntry("a").Article; root.Next == first || root.Prev == second {
+		T.Error("Expect neighbors only within a directory")
+	}
+}
+
+func TestSortOrder(T *testing.T) {
+	files := map[string]string{
+		"blog/pinned.md": "# Pinned\n\n> 05-03-2016\n> order: 2\n\nPinned\n",
+		"blog/guide.md":  "# Guide\n\n> 04-03-2016\n> order: 1\n\nGuide\n",
+	}
+	for name, content := range neighborFiles {
+		files[name] = content
+	}
+	snapshot, cleanup := loadNeighbors(T, files)
+	defer cleanup()
+
+	expected := "Guide Pinned First Second"
+	sorted := snapshot.GetDir("blog").SortedArticles
+	if result := strings.Join(titles(sorted), " "); result != expected {
+		T.Errorf("Expect %v, got %v", expected, result)
+	}
+	if sorted[1].Next != sorted[2] || sorted[2].Prev != sorted[1] {
+		T.Error("Expect neighbors in custom order")
+	}
+
+	// site wide lists stay ordered by date
+	if last := snapshot.data.SortedArticles[len(snapshot.data.SortedArticles)-1]; last.Title != "Pinned" {
+		T.Error("Expect articles by date, got", last.Title)
+	}
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+)
+
+// PageCache keeps rendered pages of a snapshot. A new snapshot starts with
+// an empty cache, so pages are never stale. When the cache is full, least
+// recently used pages are removed.
+type PageCache struct {
+	mutex   sync.Mutex
+	pages   map[string]*list.Element
+	lru     *list.List
+	size    int
+	maxSize int
+}
+
+type CachedPage struct {
+	Body []byte
+	ETag string
+}
+
+type pageCacheItem struct {
+	key  string
+	page *CachedPage
+}
+
+func newPageCache(maxSize int) *PageCache {
+	if maxSize <= 0 {
+		return nil
+	}
+	return &PageCache{pages: make(map[string]*list.Element), lru: list.New(), maxSize: maxSize}
+}
+
+// Get returns a cached page. A nil cache is always empty.
+func (c *PageCache) Get(key string) *CachedPage {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elem := c.pages[key]
+	if elem == nil {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*pageCacheItem).page
+}
+
+// Put caches page, which must not be modified afterward. Pages larger than
+// the cache are not cached.
+func (c *PageCache) Put(key string, page *CachedPage) {
+	if c == nil || len(page.Body) > c.maxSize {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.pages[key] != nil {
+		return
+	}
+	for c.size+len(page.Body) > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+	c.pages[key] = c.lru.PushFront(&pageCacheItem{key: key, page: page})
+	c.size += len(page.Body)
+}
+
+func (c *PageCache) remove(elem *list.Element) {
+	item := c.lru.Remove(elem).(*pageCacheItem)
+	delete(c.pages, item.key)
+	c.size -= len(item.page.Body)
+}
+
+// Purge removes all pages and returns how many were removed.
+func (c *PageCache) Purge() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	n := len(c.pages)
+	c.pages = make(map[string]*list.Element)
+	c.lru.Init()
+	c.size = 0
+	return n
+}
+
+// Size returns the number of cached pages and their total size in bytes.
+func (c *PageCache) Size() (int, int) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.pages), c.size
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPageCacheLRU(T *testing.T) {
+	cache := newPageCache(30)
+	page := func(body string) *CachedPage {
+		return &CachedPage{Body: []byte(body)}
+	}
+	cache.Put("a", page("0123456789"))
+	cache.Put("b", page("0123456789"))
+	cache.Put("c", page("0123456789"))
+	cache.Get("a")
+	cache.Put("d", page("0123456789"))
+	if cache.Get("b") != nil || cache.Get("a") == nil || cache.Get("c") == nil || cache.Get("d") == nil {
+		T.Error("Expect least recently used page removed")
+	}
+
+	// pages larger than the cache are not cached, and do not evict others
+	cache.Put("e", page(strings.Repeat("x", 31)))
+	if n, size := cache.Size(); cache.Get("e") != nil || n != 3 || size != 30 {
+		T.Error("Expect large page not cached, got", n, size)
+	}
+
+	cache.Put("f", page(strings.Repeat("x", 25)))
+	if n, size := cache.Size(); n != 1 || size != 25 || cache.Get("f") == nil {
+		T.Error("Expect pages removed to make room, got", n, size)
+	}
+}
@@ -2,33 +2,81 @@ package store
 
 import (
 	"errors"
+	"fmt"
 	"html/template"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/russross/blackfriday"
 )
 
 type Article struct {
-	Date time.Time
-	Tags []string
+	Date    time.Time
+	Tags    []string
+	Authors []string
 
 	Title       string
 	Short       string
 	RawContent  string
 	HtmlContent template.HTML
 	Path        template.URL
+
+	// Language, empty when Languages is not configured
+	Lang string
+	// Same article in other languages
+	Translations []*Article
+
+	// Image for social sharing, from "image:" metadata. Relative paths are
+	// resolved against the article directory on load.
+	Image string
+
+	// Neighbors in the directory listing, nil at both ends.
+	Prev *Article
+	Next *Article
+
+	// Navigation inside the series, nil if the article is not in a series.
+	Series *ArticleSeries
+
+	seriesName string
+	seriesPart int
+
+	// position in the directory listing from "order:", 0 when not set
+	order int
+
+	// entry path without language prefix, same for all translations
+	translationKey string
+
+	// sorted by score, see Related
+	related []*Article
+
+	// tokens of RawContent, computed once and shared by copies
+	termCounts map[string]int
 }
 
 func parseArticle(input string) (*Article, error) {
+	return parseArticleFile("", input, nil)
+}
+
+// parseArticleFile parses an article and expands its shortcodes. The path
+// is only used for error messages.
+func parseArticleFile(path, input string, shortcodes shortcodeSet) (*Article, error) {
 	p := &parserStruct{}
 	article, err := p.parse(input)
 	if err != nil {
 		return nil, err
 	}
 
-	article.HtmlContent = template.HTML(strings.TrimSpace(string(
-		blackfriday.MarkdownCommon([]byte(article.RawContent)))))
+	content, rendered, err := shortcodes.expand(article.RawContent, path, p.contentLine)
+	if err != nil {
+		return nil, err
+	}
+
+	html := string(blackfriday.MarkdownCommon([]byte(content)))
+	html = replaceShortcodes(html, rendered)
+	article.HtmlContent = template.HTML(strings.TrimSpace(html))
 	return article, nil
 }
 
@@ -37,12 +85,19 @@ type parserStruct struct {
 	input   string
 
 	processingInput string
+
+	// line number where RawContent starts
+	contentLine int
+
+	// line number of the last read line, for errors
+	line int
 }
 
 func (p *parserStruct) parse(input string) (*Article, error) {
 	parseFuncs := [...]func() error{
 		p.parseTitle,
 		p.parseInfo,
+		p.parseMeta,
 		p.parseShort,
 		p.parseContent,
 	}
@@ -52,22 +107,39 @@ func (p *parserStruct) parse(input string) (*Article, error) {
 	for step := 0; step < len(parseFuncs); step++ {
 		err := parseFuncs[step]()
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Line: p.line, Err: err}
 		}
 	}
 	return &p.article, nil
 }
 
+// ParseError is an error in article header.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %v: %v", e.Line, e.Err)
+}
+
+// lineAt returns line number of an offset in input, starting from 1.
+func (p *parserStruct) lineAt(offset int) int {
+	return strings.Count(p.input[:offset], "\n") + 1
+}
+
 var (
 	ErrEOF      = errors.New("EOF")
 	ErrNoPrefix = errors.New("NoPrefix")
 	ErrTitle    = errors.New("Missing title")
 	ErrDate     = errors.New("Missing date")
 	ErrContent  = errors.New("Missing content")
+	ErrMeta     = errors.New("Invalid metadata")
 )
 
 func (p *parserStruct) readLine(prefix string) (string, error) {
 	for {
+		p.line = p.lineAt(len(p.input) - len(p.processingInput))
 		index := strings.Index(p.processingInput, "\n")
 		if index < 0 {
 			return "", ErrEOF
@@ -134,6 +206,80 @@ func (p *parserStruct) parseInfo() error {
 	return nil
 }
 
+// Metadata lines follow the info line:
+//
+//	> 20-10-2016 #foo
+//	> author: thanh, huy
+//	> series: Distributed Systems
+//	> series-part: 2
+//	> order: 1
+//	> image: /static/cover.png
+var reMeta = regexp.MustCompile(`^([a-z][a-z-]*):\s*(.*)$`)
+
+func (p *parserStruct) parseMeta() error {
+	for {
+		processingInput := p.processingInput
+		line, err := p.readLine(">")
+		if err != nil {
+			break
+		}
+
+		m := reMeta.FindStringSubmatch(line)
+		if m == nil {
+			p.processingInput = processingInput
+			break
+		}
+		ok, err := p.setMeta(m[1], strings.TrimSpace(m[2]))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// not metadata, leave it for short description
+			p.processingInput = processingInput
+			break
+		}
+	}
+	return nil
+}
+
+// setMeta returns false for unknown keys.
+func (p *parserStruct) setMeta(key, value string) (bool, error) {
+	switch key {
+	case "author":
+		p.article.Authors = splitList(value)
+	case "series":
+		p.article.seriesName = value
+	case "series-part":
+		part, err := strconv.Atoi(value)
+		if err != nil || part < 1 {
+			return false, ErrMeta
+		}
+		p.article.seriesPart = part
+	case "order":
+		order, err := strconv.Atoi(value)
+		if err != nil || order < 1 {
+			return false, ErrMeta
+		}
+		p.article.order = order
+	case "image":
+		p.article.Image = value
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func (p *parserStruct) parseShort() error {
 	short := ""
 	for {
@@ -156,5 +302,8 @@ func (p *parserStruct) parseContent() error {
 		return ErrContent
 	}
 	p.article.RawContent = content
+
+	offset := len(p.input) - len(strings.TrimLeftFunc(p.processingInput, unicode.IsSpace))
+	p.contentLine = p.lineAt(offset)
 	return nil
 }
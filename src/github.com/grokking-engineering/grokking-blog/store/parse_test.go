@@ -27,6 +27,15 @@ Hello!
 
     > 20-10-2016
 
+Hello!
+`, `
+# Hello world
+
+> 20-10-2016 #foo
+> author: thanh, huy
+>
+> Welcome!
+
 Hello!
 `,
 }
@@ -59,6 +68,16 @@ var expectedArticles = []Article{
 		Tags: nil,
 		Date: MustParseDate("20-10-2016"),
 	},
+	{
+		Title:       "Hello world",
+		Short:       "Welcome!",
+		RawContent:  "Hello!",
+		HtmlContent: "<p>Hello!</p>",
+
+		Tags:    []string{"foo"},
+		Authors: []string{"thanh", "huy"},
+		Date:    MustParseDate("20-10-2016"),
+	},
 }
 
 var testDataError = [][2]string{
@@ -114,6 +133,29 @@ func TestLoadArticle(T *testing.T) {
 		if strings.Join(article.Tags, ",") != strings.Join(expected.Tags, ",") {
 			T.Error("Expect tags")
 		}
+		if strings.Join(article.Authors, ",") != strings.Join(expected.Authors, ",") {
+			T.Error("Expect authors")
+		}
+	}
+}
+
+func TestLoadArticleErrorLine(T *testing.T) {
+	_, err := parseArticle("\n# Hello world\n\n> 20-10-2016\n> series-part: x\n\nHello!\n")
+	parseErr, ok := err.(*ParseError)
+	if !ok || parseErr.Line != 5 || parseErr.Err != ErrMeta {
+		T.Error("Expect error at line 5", err)
+	}
+
+	_, err = parseArticle("\n# Hello world\n\n> 20-10-2016\n> author: thanh\n> order: 0\n\nHello!\n")
+	parseErr, ok = err.(*ParseError)
+	if !ok || parseErr.Line != 6 || parseErr.Err != ErrMeta {
+		T.Error("Expect error at line 6", err)
+	}
+
+	_, err = parseArticle("\n# Hello world\n\n> Hello\n")
+	parseErr, ok = err.(*ParseError)
+	if !ok || parseErr.Line != 4 || parseErr.Err != ErrDate {
+		T.Error("Expect error at line 4", err)
 	}
 }
 
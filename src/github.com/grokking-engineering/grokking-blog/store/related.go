@@ -0,0 +1,255 @@
+package store
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// number of related articles kept for each article
+	kMaxRelated = 10
+
+	// number of highest weighted terms kept for each article
+	kMaxTerms = 50
+)
+
+// RelatedWeights controls how related articles are scored:
+//
+//	score = Tags * jaccard(tags) + Content * cosine(tf-idf of RawContent)
+type RelatedWeights struct {
+	Tags    float64
+	Content float64
+}
+
+var DefaultRelatedWeights = RelatedWeights{Tags: 1, Content: 1}
+
+type termWeight struct {
+	term   string
+	weight float64
+}
+
+type termWeightByWeight []termWeight
+
+func (a termWeightByWeight) Len() int      { return len(a) }
+func (a termWeightByWeight) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a termWeightByWeight) Less(i, j int) bool {
+	if a[i].weight != a[j].weight {
+		return a[i].weight > a[j].weight
+	}
+	return a[i].term < a[j].term
+}
+
+type scoredArticle struct {
+	article *Article
+	score   float64
+}
+
+// scoredBefore orders by score, then newer first, then by path.
+func scoredBefore(a, b scoredArticle) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	if !a.article.Date.Equal(b.article.Date) {
+		return a.article.Date.After(b.article.Date)
+	}
+	return a.article.Path < b.article.Path
+}
+
+// insertScored inserts s into best, which is ordered by scoredBefore and
+// keeps at most kMaxRelated articles.
+func insertScored(best []scoredArticle, s scoredArticle) []scoredArticle {
+	if len(best) == kMaxRelated && !scoredBefore(s, best[kMaxRelated-1]) {
+		return best
+	}
+	k := sort.Search(len(best), func(k int) bool {
+		return scoredBefore(s, best[k])
+	})
+	if len(best) < kMaxRelated {
+		best = append(best, scoredArticle{})
+	}
+	copy(best[k+1:], best[k:])
+	best[k] = s
+	return best
+}
+
+func tokenize(content string) []string {
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) >= 3 {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// in a deterministic order, so scores are summed in the same order
+type termVector []termWeight
+
+// termVectors returns normalized tf-idf vectors of the highest weighted
+// terms of each article.
+func termVectors(articles []*Article) []termVector {
+	counts := make([]map[string]int, len(articles))
+	docFreq := make(map[string]int)
+	for i, article := range articles {
+		counts[i] = article.countTerms()
+		for term := range counts[i] {
+			docFreq[term]++
+		}
+	}
+
+	n := float64(len(articles))
+	vectors := make([]termVector, len(articles))
+	for i := range articles {
+		var terms []termWeight
+		for term, count := range counts[i] {
+			idf := math.Log(n / float64(docFreq[term]))
+			if idf > 0 {
+				terms = append(terms, termWeight{term, float64(count) * idf})
+			}
+		}
+		sort.Sort(termWeightByWeight(terms))
+		if len(terms) > kMaxTerms {
+			terms = terms[:kMaxTerms]
+		}
+
+		var norm float64
+		for _, t := range terms {
+			norm += t.weight * t.weight
+		}
+		norm = math.Sqrt(norm)
+
+		for k := range terms {
+			terms[k].weight /= norm
+		}
+		vectors[i] = terms
+	}
+	return vectors
+}
+
+// countTerms returns occurrences of each token in RawContent. The result is
+// kept in the article and must not be modified.
+func (a *Article) countTerms() map[string]int {
+	if a.termCounts == nil {
+		a.termCounts = make(map[string]int)
+		for _, token := range tokenize(a.RawContent) {
+			a.termCounts[token]++
+		}
+	}
+	return a.termCounts
+}
+
+func tagSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	// articles have few tags, a map would be slower
+	common := 0
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				common++
+				break
+			}
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+type posting struct {
+	article int
+	weight  float64
+}
+
+// buildRelated computes related articles of each article. The articles
+// must be in a deterministic order.
+func buildRelated(articles []*Article, weights RelatedWeights) {
+	var vectors []termVector
+	postings := make(map[string][]posting)
+	if weights.Content != 0 {
+		vectors = termVectors(articles)
+		for i, vector := range vectors {
+			for _, t := range vector {
+				postings[t.term] = append(postings[t.term], posting{i, t.weight})
+			}
+		}
+	}
+
+	tagged := make(map[string][]int)
+	if weights.Tags != 0 {
+		for i, article := range articles {
+			for _, tag := range article.Tags {
+				tagged[tag] = append(tagged[tag], i)
+			}
+		}
+	}
+
+	// reused for each article, only touched scores are reset
+	scores := make([]float64, len(articles))
+	touched := make([]bool, len(articles))
+	tagVisited := make([]int, len(articles)) // i+1 when visited for article i
+	var candidates []int
+
+	for i, article := range articles {
+		// only articles sharing a term or a tag can score above zero
+		candidates = candidates[:0]
+		add := func(j int, score float64) {
+			if !touched[j] {
+				touched[j] = true
+				candidates = append(candidates, j)
+			}
+			scores[j] += score
+		}
+
+		if vectors != nil {
+			for _, t := range vectors[i] {
+				for _, p := range postings[t.term] {
+					if p.article != i {
+						add(p.article, weights.Content*t.weight*p.weight)
+					}
+				}
+			}
+		}
+		for _, tag := range article.Tags {
+			for _, j := range tagged[tag] {
+				// score each article once, even when sharing many tags
+				if j != i && tagVisited[j] != i+1 {
+					tagVisited[j] = i + 1
+					add(j, weights.Tags*tagSimilarity(article.Tags, articles[j].Tags))
+				}
+			}
+		}
+
+		var best []scoredArticle
+		for _, j := range candidates {
+			score := scores[j]
+			scores[j] = 0
+			touched[j] = false
+			if score <= 0 {
+				continue
+			}
+			best = insertScored(best, scoredArticle{articles[j], score})
+		}
+
+		article.related = make([]*Article, len(best))
+		for k, s := range best {
+			article.related[k] = s.article
+		}
+	}
+}
+
+// Related returns up to n related articles.
+func (a *Article) Related(n int) []*Article {
+	if n > len(a.related) {
+		n = len(a.related)
+	}
+	if n < 0 {
+		n = 0
+	}
+	return a.related[:n]
+}
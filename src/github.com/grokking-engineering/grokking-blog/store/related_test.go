@@ -0,0 +1,54 @@
+package store
+
+import (
+	"testing"
+)
+
+func testRelatedArticles() []*Article {
+	return []*Article{
+		{Path: "a", Tags: []string{"go", "web"}, RawContent: "Golang http server with middlewares"},
+		{Path: "b", Tags: []string{"go"}, RawContent: "Golang channels and goroutines"},
+		{Path: "c", Tags: []string{"web"}, RawContent: "Writing http server middlewares"},
+		{Path: "d", Tags: []string{"food"}, RawContent: "Cooking pho at home"},
+	}
+}
+
+func TestBuildRelated(T *testing.T) {
+	articles := testRelatedArticles()
+	buildRelated(articles, DefaultRelatedWeights)
+
+	a := articles[0]
+	if len(a.Related(5)) != 2 || a.Related(5)[0].Path != "c" || a.Related(5)[1].Path != "b" {
+		T.Error("Expect related c, b", a.Related(5))
+	}
+	if len(a.Related(1)) != 1 {
+		T.Error("Expect limit")
+	}
+	if len(articles[3].Related(5)) != 0 {
+		T.Error("Expect no related article")
+	}
+
+	// tags only
+	articles = testRelatedArticles()
+	buildRelated(articles, RelatedWeights{Tags: 1})
+	if related := articles[1].Related(5); len(related) != 1 || related[0].Path != "a" {
+		T.Error("Expect related a", related)
+	}
+}
+
+func TestBuildRelatedDeterministic(T *testing.T) {
+	expected := testRelatedArticles()
+	buildRelated(expected, DefaultRelatedWeights)
+	for n := 0; n < 20; n++ {
+		articles := testRelatedArticles()
+		buildRelated(articles, DefaultRelatedWeights)
+		for i := range articles {
+			related := articles[i].Related(10)
+			for k := range related {
+				if related[k].Path != expected[i].Related(10)[k].Path {
+					T.Fatal("Expect same result")
+				}
+			}
+		}
+	}
+}
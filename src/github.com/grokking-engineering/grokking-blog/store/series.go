@@ -0,0 +1,71 @@
+package store
+
+import (
+	"sort"
+)
+
+// Series groups articles with the same "series" metadata.
+type Series struct {
+	Name  string
+	Parts []*Article
+}
+
+// ArticleSeries is the position of an article in its series.
+type ArticleSeries struct {
+	*Series
+
+	// Index in Parts, starting from 0
+	Index int
+	Prev  *Article
+	Next  *Article
+}
+
+// Number is the part number, starting from 1.
+func (s *ArticleSeries) Number() int {
+	return s.Index + 1
+}
+
+type articleBySeriesPart []*Article
+
+func (a articleBySeriesPart) Len() int      { return len(a) }
+func (a articleBySeriesPart) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a articleBySeriesPart) Less(i, j int) bool {
+	// parts without number (0) come after numbered parts
+	if a[i].seriesPart == 0 || a[j].seriesPart == 0 {
+		return a[j].seriesPart == 0 && a[i].seriesPart != 0
+	}
+	return a[i].seriesPart < a[j].seriesPart
+}
+
+// buildSeries groups sorted articles by series. Parts are ordered by
+// "series-part", then by date. Parts without "series-part" come last.
+func buildSeries(sortedArticles []*Article) map[string]*Series {
+	allSeries := make(map[string]*Series)
+	for _, article := range sortedArticles {
+		name := article.seriesName
+		if name == "" {
+			continue
+		}
+		series := allSeries[name]
+		if series == nil {
+			series = &Series{Name: name}
+			allSeries[name] = series
+		}
+		series.Parts = append(series.Parts, article)
+	}
+
+	for _, series := range allSeries {
+		sort.Stable(articleBySeriesPart(series.Parts))
+		for i, article := range series.Parts {
+			nav := &ArticleSeries{Series: series, Index: i}
+			if i > 0 {
+				nav.Prev = series.Parts[i-1]
+			}
+			if i < len(series.Parts)-1 {
+				nav.Next = series.Parts[i+1]
+			}
+			article.Series = nav
+		}
+	}
+	return allSeries
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestBuildSeries(T *testing.T) {
+	a := &Article{Title: "a", seriesName: "S"}
+	b := &Article{Title: "b", seriesName: "S", seriesPart: 2}
+	c := &Article{Title: "c", seriesName: "S", seriesPart: 1}
+	d := &Article{Title: "d"}
+	e := &Article{Title: "e", seriesName: "S"}
+
+	allSeries := buildSeries([]*Article{a, b, c, d, e})
+	series := allSeries["S"]
+	if len(allSeries) != 1 || series == nil {
+		T.Fatal("Expect one series", allSeries)
+	}
+
+	// parts without series-part come last, in date order
+	expected := []*Article{c, b, a, e}
+	for i, article := range expected {
+		if series.Parts[i] != article || article.Series.Index != i {
+			T.Error("Expect part", i, article.Title)
+		}
+	}
+	if c.Series.Prev != nil || c.Series.Next != b || a.Series.Prev != b || a.Series.Next != e || e.Series.Next != nil {
+		T.Error("Expect prev and next")
+	}
+	if d.Series != nil {
+		T.Error("Expect no series")
+	}
+}
@@ -0,0 +1,264 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/russross/blackfriday"
+)
+
+// ShortcodeContext is passed to shortcode templates.
+//
+//	{{< figure src="x.png" caption="Hello" >}}
+//	{{< callout kind="warning" >}}Markdown *content*{{< /callout >}}
+type ShortcodeContext struct {
+	Name   string
+	Params map[string]string
+	Inner  template.HTML
+}
+
+func (c *ShortcodeContext) Get(key string) string {
+	return c.Params[key]
+}
+
+type ShortcodeError struct {
+	File string
+	Line int
+	Name string
+	Err  error
+}
+
+func (e *ShortcodeError) Error() string {
+	return fmt.Sprintf("%v:%v: shortcode %q: %v", e.File, e.Line, e.Name, e.Err)
+}
+
+type shortcodeSet map[string]*template.Template
+
+var (
+	reShortcode      = regexp.MustCompile(`\{\{<\s*(/?)([\w-]+)((?:\s+[\w-]+="[^"]*")*)\s*>\}\}`)
+	reShortcodeParam = regexp.MustCompile(`([\w-]+)="([^"]*)"`)
+)
+
+// loadShortcodes parses all "<name>.tpl.html" files in dirPath. A missing
+// directory is not an error.
+func loadShortcodes(dirPath string) (shortcodeSet, error) {
+	set := make(shortcodeSet)
+	files, err := ioutil.ReadDir(dirPath)
+	if os.IsNotExist(err) {
+		return set, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".tpl.html") {
+			continue
+		}
+		path := filepath.Join(dirPath, name)
+		tpl, err := template.New(name).ParseFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		set[strings.TrimSuffix(name, ".tpl.html")] = tpl
+	}
+	return set, nil
+}
+
+type shortcodeTag struct {
+	start, end int
+	closing    bool
+	name       string
+	params     map[string]string
+}
+
+// findShortcodes returns shortcode tags of content, except those in fenced
+// code blocks and code spans which are shown as written.
+func findShortcodes(content string) []shortcodeTag {
+	var tags []shortcodeTag
+	code := codeRanges(content)
+	for _, m := range reShortcode.FindAllStringSubmatchIndex(content, -1) {
+		if inRanges(code, m[0]) {
+			continue
+		}
+		tag := shortcodeTag{
+			start:   m[0],
+			end:     m[1],
+			closing: m[3] > m[2],
+			name:    content[m[4]:m[5]],
+			params:  make(map[string]string),
+		}
+		for _, p := range reShortcodeParam.FindAllStringSubmatch(content[m[6]:m[7]], -1) {
+			tag.params[p[1]] = p[2]
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// codeRanges returns [start, end) offsets of fenced code blocks and code
+// spans in markdown content, in order.
+func codeRanges(content string) [][2]int {
+	var fences [][2]int
+	fence, fenceStart := "", 0
+	for offset := 0; offset < len(content); {
+		lineEnd := strings.IndexByte(content[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += offset
+		}
+		line := strings.TrimLeft(content[offset:lineEnd], " ")
+		indented := lineEnd-offset-len(line) > 3
+		switch {
+		case indented:
+		case fence == "" && (strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")):
+			fence = line[:len(line)-len(strings.TrimLeft(line, line[:1]))]
+			fenceStart = offset
+		case fence != "" && strings.HasPrefix(line, fence) && strings.TrimSpace(strings.TrimLeft(line, fence[:1])) == "":
+			fences = append(fences, [2]int{fenceStart, lineEnd})
+			fence = ""
+		}
+		offset = lineEnd + 1
+	}
+	if fence != "" {
+		// an unclosed fence runs to the end
+		fences = append(fences, [2]int{fenceStart, len(content)})
+	}
+
+	// code spans end with a run of as many backticks as they start with
+	var ranges [][2]int
+	backticks := func(i int) int {
+		n := 0
+		for i+n < len(content) && content[i+n] == '`' {
+			n++
+		}
+		return n
+	}
+	next := 0
+	for i := 0; i < len(content); {
+		if next < len(fences) && i >= fences[next][0] {
+			ranges = append(ranges, fences[next])
+			i = fences[next][1]
+			next++
+			continue
+		}
+		n := backticks(i)
+		if n == 0 {
+			i++
+			continue
+		}
+		limit := len(content)
+		if next < len(fences) {
+			limit = fences[next][0]
+		}
+		end := -1
+		for j := i + n; j < limit; {
+			if m := backticks(j); m == n {
+				end = j + m
+				break
+			} else if m > 0 {
+				j += m
+			} else {
+				j++
+			}
+		}
+		if end < 0 {
+			i += n
+			continue
+		}
+		ranges = append(ranges, [2]int{i, end})
+		i = end
+	}
+	return ranges
+}
+
+func inRanges(ranges [][2]int, offset int) bool {
+	for _, r := range ranges {
+		if offset >= r[0] && offset < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// expand replaces every shortcode in content with a placeholder
+// and returns the rendered html for each placeholder. Placeholders survive
+// markdown rendering and are substituted by replaceShortcodes afterward.
+// Line numbers in errors are counted from firstLine.
+func (set shortcodeSet) expand(content, file string, firstLine int) (string, map[string]template.HTML, error) {
+	tags := findShortcodes(content)
+	if len(tags) == 0 {
+		return content, nil, nil
+	}
+
+	lineOf := func(offset int) int {
+		return firstLine + strings.Count(content[:offset], "\n")
+	}
+	fail := func(tag shortcodeTag, err error) error {
+		return &ShortcodeError{File: file, Line: lineOf(tag.start), Name: tag.name, Err: err}
+	}
+
+	buf := &bytes.Buffer{}
+	rendered := make(map[string]template.HTML)
+	last := 0
+	for i := 0; i < len(tags); i++ {
+		tag := tags[i]
+		if tag.closing {
+			return "", nil, fail(tag, fmt.Errorf("closing tag without opening tag"))
+		}
+
+		tpl := set[tag.name]
+		if tpl == nil {
+			return "", nil, fail(tag, fmt.Errorf("template not found in _shortcodes"))
+		}
+
+		ctx := &ShortcodeContext{Name: tag.name, Params: tag.params}
+		end := tag.end
+
+		// look for the matching closing tag, shortcodes do not nest
+		for j := i + 1; j < len(tags); j++ {
+			if tags[j].closing && tags[j].name == tag.name {
+				inner := strings.TrimSpace(content[tag.end:tags[j].start])
+				ctx.Inner = template.HTML(strings.TrimSpace(string(
+					blackfriday.MarkdownCommon([]byte(inner)))))
+				end = tags[j].end
+				i = j
+				break
+			}
+			if !tags[j].closing && tags[j].name == tag.name {
+				break
+			}
+		}
+
+		out := &bytes.Buffer{}
+		err := tpl.Execute(out, ctx)
+		if err != nil {
+			return "", nil, fail(tag, err)
+		}
+
+		placeholder := fmt.Sprintf("grokkingshortcode%vx", len(rendered))
+		rendered[placeholder] = template.HTML(strings.TrimSpace(out.String()))
+
+		buf.WriteString(content[last:tag.start])
+		buf.WriteString(placeholder)
+		last = end
+	}
+	buf.WriteString(content[last:])
+	return buf.String(), rendered, nil
+}
+
+func replaceShortcodes(html string, rendered map[string]template.HTML) string {
+	for placeholder, out := range rendered {
+		html = strings.Replace(html, "<p>"+placeholder+"</p>", string(out), -1)
+		html = strings.Replace(html, placeholder, string(out), -1)
+	}
+	return html
+}
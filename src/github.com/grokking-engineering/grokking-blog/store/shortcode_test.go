@@ -0,0 +1,84 @@
+package store
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func testShortcodes() shortcodeSet {
+	return shortcodeSet{
+		"figure": template.Must(template.New("figure").Parse(
+			`<figure><img src="{{.Params.src}}"><figcaption>{{.Get "caption"}}</figcaption></figure>`)),
+		"callout": template.Must(template.New("callout").Parse(
+			`<div class="callout">{{.Inner}}</div>`)),
+	}
+}
+
+func TestExpandShortcodes(T *testing.T) {
+	input := `Hello
+
+{{< figure src="x.png" caption="A & B" >}}
+
+{{< callout >}}Inner{{< /callout >}}`
+
+	content, rendered, err := testShortcodes().expand(input, "a.md", 1)
+	if err != nil {
+		T.Fatal("Unexpected error", err)
+	}
+	if strings.Contains(content, "{{<") || len(rendered) != 2 {
+		T.Error("Expect shortcodes replaced", content)
+	}
+
+	html := replaceShortcodes(content, rendered)
+	if !strings.Contains(html, `<figure><img src="x.png"><figcaption>A &amp; B</figcaption></figure>`) {
+		T.Error("Expect figure", html)
+	}
+	if !strings.Contains(html, `<div class="callout"><p>Inner</p></div>`) {
+		T.Error("Expect callout", html)
+	}
+}
+
+func TestShortcodesInCode(T *testing.T) {
+	input := "Use `{{< figure src=\"x.png\" >}}` or ``{{< callout >}}` ``:\n\n" +
+		"```\n{{< figure src=\"y.png\" >}}\n```\n\n" +
+		"  ~~~~ html\n{{< callout >}}Inner{{< /callout >}}\n~~~\n~~~~\n\n" +
+		"{{< figure src=\"z.png\" >}}\n"
+
+	content, rendered, err := testShortcodes().expand(input, "a.md", 1)
+	if err != nil {
+		T.Fatal("Unexpected error", err)
+	}
+	if len(rendered) != 1 || strings.Contains(content, "z.png") {
+		T.Error("Expect only shortcode outside code expanded", content)
+	}
+	for _, code := range []string{"`{{< figure src=\"x.png\" >}}`", "``{{< callout >}}` ``", "{{< figure src=\"y.png\" >}}", "{{< callout >}}Inner{{< /callout >}}"} {
+		if !strings.Contains(content, code) {
+			T.Error("Expect code kept", code)
+		}
+	}
+
+	// unclosed fences run to the end
+	content, rendered, err = testShortcodes().expand("```\n{{< unknown >}}\n", "a.md", 1)
+	if err != nil || len(rendered) != 0 {
+		T.Error("Expect shortcode in unclosed fence kept", content, err)
+	}
+}
+
+func TestExpandShortcodesError(T *testing.T) {
+	input := "# Hello\n\n> 20-10-2016\n\nLine 5\n\n{{< unknown >}}\n"
+
+	_, err := parseArticleFile("blog/a.md", input, testShortcodes())
+	scErr, ok := err.(*ShortcodeError)
+	if !ok {
+		T.Fatal("Expect ShortcodeError", err)
+	}
+	if scErr.File != "blog/a.md" || scErr.Line != 7 || scErr.Name != "unknown" {
+		T.Error("Expect file and line", scErr)
+	}
+
+	_, _, err = testShortcodes().expand("{{< /callout >}}", "a.md", 1)
+	if err == nil {
+		T.Error("Expect error for closing tag")
+	}
+}
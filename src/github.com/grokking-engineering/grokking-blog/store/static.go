@@ -0,0 +1,286 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grokking-engineering/grokking-blog/utils/minify"
+)
+
+// StaticManifest maps files in static dir to fingerprinted names, which
+// change with file content, so they can be cached forever:
+//
+//	main.css      /static/main.3f2a9c1b.css
+//	img/logo.png  /static/img/logo.5d0e7a42.png
+//
+// Pre-compressed ".gz" and ".br" files are served in place of their original
+// and are not listed. Bundles are listed like files, their content is kept in
+// memory.
+type StaticManifest struct {
+	// name relative to static dir, with slashes
+	files map[string]*staticFile
+	// fingerprinted name to name
+	names map[string]string
+	// fingerprinted names of the previous manifest which changed, to name
+	stale map[string]string
+}
+
+type staticFile struct {
+	modTime     time.Time
+	size        int64
+	fingerprint string
+
+	// only for bundles
+	content []byte
+	inputs  string
+}
+
+// buildStaticManifest hashes files of staticDir and builds bundles, reusing
+// unchanged files and bundles from previous, which may be nil.
+func buildStaticManifest(staticDir string, bundles map[string][]string, previous *StaticManifest) (*StaticManifest, error) {
+	m := &StaticManifest{
+		files: make(map[string]*staticFile),
+		names: make(map[string]string),
+	}
+	err := filepath.Walk(staticDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		baseName := info.Name()
+		if strings.HasPrefix(baseName, ".") && filePath != staticDir {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ext := filepath.Ext(baseName)
+		if ext == ".gz" || ext == ".br" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(staticDir, filePath)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		file := previous.file(name)
+		if file == nil || file.content != nil || !file.modTime.Equal(info.ModTime()) || file.size != info.Size() {
+			hash, err := hashFile(filePath)
+			if err != nil {
+				return err
+			}
+			file = &staticFile{
+				modTime:     info.ModTime(),
+				size:        info.Size(),
+				fingerprint: fingerprintName(name, hash),
+			}
+		}
+		m.files[name] = file
+		m.names[file.fingerprint] = name
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(bundles))
+	for name := range bundles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file, err := m.buildBundle(staticDir, name, bundles[name], previous)
+		if err != nil {
+			return nil, fmt.Errorf("Bundle %v: %v", name, err)
+		}
+		m.files[name] = file
+		m.names[file.fingerprint] = name
+	}
+
+	// pages rendered before the reload may still be requesting old names
+	if previous != nil {
+		m.stale = make(map[string]string)
+		for fingerprint, name := range previous.names {
+			if _, ok := m.names[fingerprint]; !ok {
+				m.stale[fingerprint] = name
+			}
+		}
+	}
+	return m, nil
+}
+
+// buildBundle concatenates and minifies inputs of a ".css" or ".js" bundle.
+// It is only built again when an input changes.
+func (m *StaticManifest) buildBundle(staticDir, name string, inputs []string, previous *StaticManifest) (*staticFile, error) {
+	var minifyFunc func([]byte) []byte
+	separator := []byte("\n")
+	switch path.Ext(name) {
+	case ".css":
+		minifyFunc = minify.CSS
+	case ".js":
+		minifyFunc = minify.JS
+		// inputs may end without a semicolon, or with a line comment
+		separator = []byte("\n;\n")
+	default:
+		return nil, errors.New("Only .css and .js bundles are supported")
+	}
+	if m.files[name] != nil {
+		return nil, errors.New("Static file exists with the same name")
+	}
+
+	sig := ""
+	var modTime time.Time
+	for _, input := range inputs {
+		input = strings.TrimPrefix(path.Clean("/"+input), "/")
+		file := m.files[input]
+		if file == nil {
+			return nil, errors.New("Static file not exist: " + input)
+		}
+		sig += fmt.Sprintf("%v %v %v;", input, file.fingerprint, file.size)
+		if file.modTime.After(modTime) {
+			modTime = file.modTime
+		}
+	}
+	if file := previous.file(name); file != nil && file.content != nil && file.inputs == sig {
+		return file, nil
+	}
+
+	var buf bytes.Buffer
+	for i, input := range inputs {
+		data, err := ioutil.ReadFile(filepath.Join(staticDir, filepath.FromSlash(path.Clean("/"+input))))
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.Write(separator)
+		}
+		buf.Write(data)
+	}
+	content := minifyFunc(buf.Bytes())
+
+	sum := sha1.Sum(content)
+	return &staticFile{
+		modTime:     modTime,
+		size:        int64(len(content)),
+		fingerprint: fingerprintName(name, hex.EncodeToString(sum[:])[:8]),
+		content:     content,
+		inputs:      sig,
+	}, nil
+}
+
+func (m *StaticManifest) file(name string) *staticFile {
+	if m == nil {
+		return nil
+	}
+	return m.files[name]
+}
+
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:8], nil
+}
+
+// fingerprintName inserts hash before the extension.
+func fingerprintName(name, hash string) string {
+	ext := path.Ext(name)
+	if ext == "" || ext == path.Base(name) {
+		return name + "." + hash
+	}
+	return name[:len(name)-len(ext)] + "." + hash + ext
+}
+
+// URL returns the fingerprinted url of name, relative to static dir. A nil
+// manifest returns plain urls.
+func (m *StaticManifest) URL(name string) (string, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if m == nil {
+		return "/static/" + name, nil
+	}
+	file := m.files[name]
+	if file == nil {
+		return "", errors.New("Static file not exist: " + name)
+	}
+	return "/static/" + file.fingerprint, nil
+}
+
+// Bundle returns content of a bundle, or false when name is not a bundle.
+func (m *StaticManifest) Bundle(name string) ([]byte, time.Time, bool) {
+	file := m.file(name)
+	if file == nil || file.content == nil {
+		return nil, time.Time{}, false
+	}
+	return file.content, file.modTime, true
+}
+
+// Lookup returns the name of a fingerprinted name, e.g. "main.css" for
+// "main.3f2a9c1b.css".
+func (m *StaticManifest) Lookup(fingerprint string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	name, ok := m.names[fingerprint]
+	return name, ok
+}
+
+// LookupStale returns the name of a fingerprinted name of the previous
+// manifest, whose file changed since.
+func (m *StaticManifest) LookupStale(fingerprint string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	name, ok := m.stale[fingerprint]
+	return name, ok
+}
+
+// WriteBundles builds bundles without loading content and writes them to
+// outDir, by name and fingerprinted name. It returns written file names.
+func (this *Instance) WriteBundles(outDir string) ([]string, error) {
+	m, err := buildStaticManifest(this.StaticDir, this.Bundles, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for fingerprint, name := range m.names {
+		file := m.files[name]
+		if file.content == nil {
+			continue
+		}
+		for _, outName := range []string{name, fingerprint} {
+			outPath := filepath.Join(outDir, filepath.FromSlash(outName))
+			if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+				return written, err
+			}
+			if err := ioutil.WriteFile(outPath, file.content, 0644); err != nil {
+				return written, err
+			}
+			written = append(written, outName)
+		}
+	}
+	sort.Strings(written)
+	return written, nil
+}
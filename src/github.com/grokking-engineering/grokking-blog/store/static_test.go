@@ -0,0 +1,154 @@
+package store
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFingerprintName(T *testing.T) {
+	for name, expected := range map[string]string{
+		"main.css":      "main.abc.css",
+		"img/logo.png":  "img/logo.abc.png",
+		"LICENSE":       "LICENSE.abc",
+		"js/app.min.js": "js/app.min.abc.js",
+		"fonts.d/x":     "fonts.d/x.abc",
+	} {
+		if actual := fingerprintName(name, "abc"); actual != expected {
+			T.Errorf("%v: expect %v, got %v", name, expected, actual)
+		}
+	}
+}
+
+func TestStaticManifest(T *testing.T) {
+	contentDir := writeFiles(T, map[string]string{
+		"_layout_main.tpl.html": `<link href="{{asset "main.css"}}">{{.Content}}`,
+		"_layout.tpl.html":      `{{.HtmlContent}}`,
+		"index.md":              "# Home\n\n> 01-03-2016\n\nHome\n",
+	})
+	defer os.RemoveAll(contentDir)
+	staticDir := writeFiles(T, map[string]string{
+		"main.css":    "body{}",
+		"main.css.gz": "gzipped",
+		"img/a.png":   "png",
+		".hidden/x":   "x",
+	})
+	defer os.RemoveAll(staticDir)
+
+	store := &Instance{ContentDir: contentDir, StaticDir: staticDir}
+	if err := store.Reload(); err != nil {
+		T.Fatal(err)
+	}
+	static := store.Snapshot().Static()
+	if len(static.files) != 2 {
+		T.Errorf("Expect 2 files, got %v", static.files)
+	}
+
+	url, err := static.URL("main.css")
+	if err != nil || !strings.HasPrefix(url, "/static/main.") || !strings.HasSuffix(url, ".css") || len(url) != len("/static/main.12345678.css") {
+		T.Fatal("Unexpected url", url, err)
+	}
+	if name, ok := static.Lookup(url[len("/static/"):]); !ok || name != "main.css" {
+		T.Error("Expect lookup main.css, got", name)
+	}
+	if _, err := static.URL("nope.css"); err == nil {
+		T.Error("Expect error for missing file")
+	}
+
+	var buf bytes.Buffer
+	store.GetMainLayout("").Execute(&buf, map[string]interface{}{"Content": ""})
+	if buf.String() != `<link href="`+url+`">` {
+		T.Error("Unexpected layout output", buf.String())
+	}
+
+	// changed file has a new name, old name is not served
+	later := time.Now().Add(time.Second)
+	ioutil.WriteFile(filepath.Join(staticDir, "main.css"), []byte("body{color:red}"), 0644)
+	os.Chtimes(filepath.Join(staticDir, "main.css"), later, later)
+	if err := store.Reload(); err != nil {
+		T.Fatal(err)
+	}
+	newURL, _ := store.Snapshot().Static().URL("main.css")
+	if newURL == url {
+		T.Error("Expect new fingerprint after change")
+	}
+	if _, ok := store.Snapshot().Static().Lookup(url[len("/static/"):]); ok {
+		T.Error("Expect old fingerprint not found")
+	}
+
+	// nil manifest, when StaticDir is not set
+	var none *StaticManifest
+	if url, _ := none.URL("/main.css"); url != "/static/main.css" {
+		T.Error("Expect plain url, got", url)
+	}
+}
+
+func TestStaticBundles(T *testing.T) {
+	staticDir := writeFiles(T, map[string]string{
+		"css/base.css":    "body {\n  margin: 0;\n}\n",
+		"css/article.css": "/* article */\narticle { padding: 1em; }\n",
+		"js/a.js":         "var a = 1 // one",
+		"js/b.js":         "var b = 2",
+	})
+	defer os.RemoveAll(staticDir)
+
+	bundles := map[string][]string{
+		"site.css": {"css/base.css", "css/article.css"},
+		"site.js":  {"js/a.js", "js/b.js"},
+	}
+	m, err := buildStaticManifest(staticDir, bundles, nil)
+	if err != nil {
+		T.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"site.css": "body{margin:0}article{padding:1em}",
+		"site.js":  "var a=1\n;var b=2",
+	} {
+		content, _, ok := m.Bundle(name)
+		if !ok || string(content) != expected {
+			T.Errorf("%v: expect %q, got %q", name, expected, content)
+		}
+	}
+	url, _ := m.URL("site.css")
+	if name, ok := m.Lookup(url[len("/static/"):]); !ok || name != "site.css" {
+		T.Error("Expect fingerprinted bundle, got", url)
+	}
+
+	// unchanged inputs reuse the bundle
+	m2, _ := buildStaticManifest(staticDir, bundles, m)
+	if m2.files["site.css"] != m.files["site.css"] {
+		T.Error("Expect bundle reused")
+	}
+	later := time.Now().Add(time.Second)
+	ioutil.WriteFile(filepath.Join(staticDir, "css/base.css"), []byte("html{}"), 0644)
+	os.Chtimes(filepath.Join(staticDir, "css/base.css"), later, later)
+	m3, _ := buildStaticManifest(staticDir, bundles, m2)
+	if content, _, _ := m3.Bundle("site.css"); string(content) != "html{}article{padding:1em}" {
+		T.Error("Expect bundle rebuilt, got", string(content))
+	}
+
+	for _, invalid := range []map[string][]string{
+		{"site.css": {"css/nope.css"}},
+		{"site.txt": {"css/base.css"}},
+		{"css/base.css": {"css/article.css"}},
+	} {
+		if _, err := buildStaticManifest(staticDir, invalid, nil); err == nil {
+			T.Error("Expect error for", invalid)
+		}
+	}
+
+	outDir := writeFiles(T, nil)
+	defer os.RemoveAll(outDir)
+	store := &Instance{StaticDir: staticDir, Bundles: bundles}
+	written, err := store.WriteBundles(outDir)
+	if err != nil || len(written) != 4 {
+		T.Fatal("Expect 4 files written, got", written, err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(outDir, "site.js")); string(data) != "var a=1\n;var b=2" {
+		T.Error("Unexpected written bundle", string(data))
+	}
+}
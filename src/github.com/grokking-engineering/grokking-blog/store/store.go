@@ -2,8 +2,13 @@ package store
 
 import (
 	"html/template"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/grokking-engineering/grokking-blog/images"
 	"github.com/grokking-engineering/grokking-blog/utils/logs"
 )
 
@@ -18,44 +23,318 @@ func ParseDate(s string) (time.Time, error) {
 type Instance struct {
 	ContentDir string
 
-	data *Data
+	// Optional, the first one is the default language.
+	Languages []string
+
+	// Optional
+	Images *images.Processor
+
+	// Optional, enables fingerprinted urls of the "asset" template function.
+	// Files are hashed again on reload.
+	StaticDir string
+
+	// Optional, concatenated and minified files served in StaticDir, e.g.
+	// "site.css": ["css/base.css", "css/article.css"]. Only built again when
+	// an input changes.
+	Bundles map[string][]string
+
+	// Defaults to DefaultRelatedWeights
+	Related *RelatedWeights
+
+	// Publish valid content when some entries are invalid. Invalid entries
+	// are skipped and reported by Errors(). Content is still rejected when
+	// the main layout is invalid.
+	Lenient bool
+
+	// Maximum total size in bytes of rendered pages cached by each
+	// snapshot, 0 disables the cache.
+	PageCacheSize int
+
+	// one reload at a time, they share cache
+	reloadMutex sync.Mutex
+	cache       *articleCache
+	version     int
+
+	snapshot atomic.Value // *Snapshot
+	errors   atomic.Value // LoadErrors
+}
+
+// Snapshot is content published by a reload. It is never modified, so
+// requests keep using it while the next reload is running.
+type Snapshot struct {
+	data     *Data
+	loadedAt time.Time
+	pages    *PageCache
+	static   *StaticManifest
+
+	// 1 for the first snapshot, increased by each reload
+	version int
 }
 
 func (this *Instance) Init() {
 	if this.ContentDir == "" {
 		panic("Empty ContentDir")
 	}
-	err := this.ClearCacheAndReload()
+	err := this.Reload()
 	if err != nil {
 		l.Fatal(err)
 	}
 }
 
+// ClearCacheAndReload parses all content again, see Reload.
 func (this *Instance) ClearCacheAndReload() error {
-	data, err := loadFiles(this.ContentDir)
+	this.reloadMutex.Lock()
+	this.cache = nil
+	this.reloadMutex.Unlock()
+	return this.Reload()
+}
+
+// Reload loads content again, only .md files changed since the last reload
+// are parsed. On error, it returns LoadErrors and keeps serving old content.
+// In lenient mode, it only fails when no content can be published.
+func (this *Instance) Reload() error {
+	this.reloadMutex.Lock()
+	defer this.reloadMutex.Unlock()
+
+	related := DefaultRelatedWeights
+	if this.Related != nil {
+		related = *this.Related
+	}
+
+	languages := this.Languages
+	if len(languages) == 0 {
+		// single language, articles are not split by language suffix
+		languages = []string{""}
+	}
+
+	if this.cache == nil {
+		this.cache = newArticleCache()
+	}
+	this.cache.begin(dirSignature(filepath.Join(this.ContentDir, "_shortcodes")))
+
+	var langs []*Data
+	var errs LoadErrors
+	failed := false
+
+	var static *StaticManifest
+	if this.StaticDir != "" {
+		var previous *StaticManifest
+		if snapshot := this.Snapshot(); snapshot != nil {
+			previous = snapshot.static
+		}
+		var err error
+		static, err = buildStaticManifest(this.StaticDir, this.Bundles, previous)
+		if err != nil {
+			l.WithError(err).Error("Unable to hash static files")
+			errs := LoadErrors{newLoadError(this.ContentDir, "", "static", err)}
+			this.errors.Store(errs)
+			return errs
+		}
+	}
+
+	files, err := scanContent(this.ContentDir)
 	if err != nil {
-		l.WithError(err).Error("Unable to load content!")
-		return err
+		l.WithError(err).Error("Unable to read content")
+		errs := LoadErrors{newLoadError(this.ContentDir, this.ContentDir, "read", err)}
+		this.errors.Store(errs)
+		return errs
+	}
+
+	reported := make(map[string]bool)
+	for _, lang := range languages {
+		data, loadErrs := loadFiles(this.ContentDir, files, loadOptions{
+			Languages: this.Languages,
+			Lang:      lang,
+			Images:    this.Images,
+			Related:   related,
+			Lenient:   this.Lenient,
+			Cache:     this.cache,
+			Static:    static,
+		})
+		if loadErrs != nil {
+			l.WithFields(logs.M{
+				"lang": lang,
+			}).Error("Unable to load content!")
+
+			// layouts are shared by all languages, report them once
+			for _, loadErr := range loadErrs {
+				if !reported[loadErr.Error()] {
+					reported[loadErr.Error()] = true
+					errs = append(errs, loadErr)
+				}
+			}
+		}
+		if data == nil {
+			failed = true
+			continue
+		}
+		langs = append(langs, data)
+	}
+	this.errors.Store(errs)
+	if failed {
+		// keep cached articles of files not visited
+		return errs
 	}
+	this.cache.end()
+	linkTranslations(langs)
 
-	this.data = data
-	l.Println("Loaded content")
-	for k := range data.Entries {
-		l.Println("Indexed:", k)
+	data := langs[0]
+	data.Langs = make(map[string]*Data)
+	data.AllEntries = make(map[string]*Entry)
+	for _, langData := range langs {
+		if langData != data {
+			data.Langs[langData.Lang] = langData
+		}
+		for path, entry := range langData.Entries {
+			if langData.PathPrefix != "" {
+				path = filepath.Join(langData.PathPrefix, path)
+			}
+			data.AllEntries[path] = entry
+		}
 	}
+
+	this.version++
+	this.snapshot.Store(&Snapshot{
+		data:     data,
+		loadedAt: time.Now(),
+		pages:    newPageCache(this.PageCacheSize),
+		static:   static,
+		version:  this.version,
+	})
+	l.WithFields(logs.M{
+		"entries": len(data.AllEntries),
+		"parsed":  this.cache.misses,
+		"cached":  this.cache.hits,
+	}).Info("Loaded content")
 	return nil
 }
 
+// Snapshot returns the published content, or nil before the first
+// successful reload. Use one snapshot to serve a request consistently.
+func (this *Instance) Snapshot() *Snapshot {
+	snapshot, _ := this.snapshot.Load().(*Snapshot)
+	return snapshot
+}
+
+// IsLoaded reports whether content was loaded at least once.
+func (this *Instance) IsLoaded() bool {
+	return this.Snapshot() != nil
+}
+
+// Errors returns errors of the last reload. In lenient mode, content may be
+// published with errors.
+func (this *Instance) Errors() LoadErrors {
+	errs, _ := this.errors.Load().(LoadErrors)
+	return errs
+}
+
+// LoadedAt returns the time content was last published, or zero time before
+// the first successful reload.
+func (this *Instance) LoadedAt() time.Time {
+	snapshot := this.Snapshot()
+	if snapshot == nil {
+		return time.Time{}
+	}
+	return snapshot.loadedAt
+}
+
 func (this *Instance) GetEntry(path string) *Entry {
-	entry := this.data.Entries[path]
-	return entry
+	return this.Snapshot().GetEntry(path)
+}
+
+// GetEntries returns entries of all languages by url path.
+func (this *Instance) GetEntries() map[string]*Entry {
+	return this.Snapshot().GetEntries()
+}
+
+func (this *Instance) GetAuthor(id string) *Author {
+	return this.Snapshot().GetAuthor(id)
 }
 
 func (this *Instance) GetDir(path string) *Dir {
-	dir := this.data.Dirs[path]
+	return this.Snapshot().GetDir(path)
+}
+
+// GetMainLayout returns main layout in the language of the url path.
+func (this *Instance) GetMainLayout(path string) *template.Template {
+	return this.Snapshot().GetMainLayout(path)
+}
+
+// GetAsset returns file path of an asset inside content dir, or "" if not
+// found.
+func (this *Instance) GetAsset(path string) string {
+	return this.Snapshot().GetAsset(path)
+}
+
+// dataFor returns data of the language in the url path, and the path
+// without language prefix.
+func (this *Snapshot) dataFor(path string) (*Data, string) {
+	data := this.data
+	if len(data.Langs) == 0 {
+		return data, path
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(filepath.ToSlash(path), "/"), "/", 2)
+	langData := data.Langs[parts[0]]
+	if langData == nil {
+		return data, path
+	}
+	if len(parts) == 1 {
+		return langData, "."
+	}
+	return langData, filepath.FromSlash(parts[1])
+}
+
+// Pages returns rendered pages of this snapshot, nil when disabled.
+func (this *Snapshot) Pages() *PageCache {
+	return this.pages
+}
+
+// Static returns fingerprinted names of static files, nil when StaticDir is
+// not set.
+func (this *Snapshot) Static() *StaticManifest {
+	return this.static
+}
+
+// Version identifies the snapshot, it increases with each reload.
+func (this *Snapshot) Version() int {
+	return this.version
+}
+
+// LoadedAt returns the time the snapshot was published.
+func (this *Snapshot) LoadedAt() time.Time {
+	return this.loadedAt
+}
+
+func (this *Snapshot) GetEntry(path string) *Entry {
+	data, path := this.dataFor(path)
+	entry := data.Entries[path]
+	return entry
+}
+
+// GetEntries returns entries of all languages by url path.
+func (this *Snapshot) GetEntries() map[string]*Entry {
+	return this.data.AllEntries
+}
+
+func (this *Snapshot) GetAuthor(id string) *Author {
+	return this.data.Authors[id]
+}
+
+func (this *Snapshot) GetDir(path string) *Dir {
+	data, path := this.dataFor(path)
+	dir := data.Dirs[path]
 	return dir
 }
 
-func (this *Instance) GetMainLayout() *template.Template {
-	return this.data.MainLayout
+// GetMainLayout returns main layout in the language of the url path.
+func (this *Snapshot) GetMainLayout(path string) *template.Template {
+	data, _ := this.dataFor(path)
+	return data.MainLayout
+}
+
+// GetAsset returns file path of an asset inside content dir, or "" if not
+// found.
+func (this *Snapshot) GetAsset(path string) string {
+	return this.data.Assets[filepath.ToSlash(path)]
 }
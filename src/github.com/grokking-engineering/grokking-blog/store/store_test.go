@@ -0,0 +1,42 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes files by slash separated name into a new temporary dir.
+func writeFiles(T *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "grokking-store")
+	if err != nil {
+		T.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		err := ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			T.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// loadDir loads content of dir in the default language.
+func loadDir(T *testing.T, dir string) (*Data, LoadErrors) {
+	files, err := scanContent(dir)
+	if err != nil {
+		T.Fatal(err)
+	}
+	return loadFiles(dir, files, loadOptions{})
+}
+
+func titles(articles []*Article) []string {
+	var result []string
+	for _, article := range articles {
+		result = append(result, article.Title)
+	}
+	return result
+}
@@ -72,6 +72,11 @@ func fromEnv(envMap map[string]interface{}, v interface{}, tagName string) {
 			continue
 		}
 
+		// maps can only be set from config file
+		if vField.Kind() == reflect.Map {
+			continue
+		}
+
 		if vField.Kind() != reflect.String {
 			l.WithFields(nil).Fatalf("Field %v must be a string", tField.Name)
 		}
@@ -79,6 +84,9 @@ func fromEnv(envMap map[string]interface{}, v interface{}, tagName string) {
 		env := os.Getenv(tag)
 		if env != "" {
 			envMap[tag] = env
+			if strings.HasSuffix(tag, "_TOKEN") || strings.HasSuffix(tag, "_PASSWORD") {
+				envMap[tag] = "***"
+			}
 			vField.SetString(env)
 		}
 	}
@@ -1,6 +1,7 @@
 package loadConfig
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 )
@@ -11,6 +12,7 @@ type TestConfig struct {
 		Baz struct {
 			Quix string `config:"QUIX"`
 		} `config:"baz"`
+		Dirs map[string]string `config:"dirs"`
 	} `config:"foo"`
 }
 
@@ -27,9 +29,30 @@ func TestFromEnv(T *testing.T) {
 	}
 
 	var config TestConfig
+	config.Foo.Dirs = map[string]string{"blog": "weekly"}
 	fromEnv(map[string]interface{}{}, &config, "config")
 
 	if config.Foo.Bar != "xbar" || config.Foo.Baz.Quix != "xquix" {
 		T.Error("Expect config", config)
 	}
+	// maps are skipped, they can only be set from config file
+	if len(config.Foo.Dirs) != 1 || config.Foo.Dirs["blog"] != "weekly" {
+		T.Error("Expect map from config file kept", config.Foo.Dirs)
+	}
+}
+
+func TestFromFileMap(T *testing.T) {
+	file, err := ioutil.TempFile("", "config")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`{"Foo": {"Dirs": {"blog": "weekly"}}}`)
+	file.Close()
+
+	var config TestConfig
+	err = FromFile(&config, file.Name())
+	if err != nil || config.Foo.Dirs["blog"] != "weekly" {
+		T.Error("Expect map loaded", config.Foo.Dirs, err)
+	}
 }
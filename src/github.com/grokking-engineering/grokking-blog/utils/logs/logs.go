@@ -5,6 +5,7 @@ import (
 	"runtime"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -25,11 +26,46 @@ func init() {
 	logrus.SetOutput(os.Stderr)
 }
 
+var (
+	mutex   sync.Mutex
+	loggers []*logrus.Logger
+	level   = logrus.InfoLevel
+)
+
 func New(prefix string) Logger {
 	logger := logrus.New()
+	mutex.Lock()
+	logger.SetLevel(level)
+	loggers = append(loggers, logger)
+	mutex.Unlock()
 	return Logger{logger}
 }
 
+// SetLevel sets the level of all loggers: "debug", "info", "warning",
+// "error", "fatal" or "panic".
+func SetLevel(name string) error {
+	newLevel, err := logrus.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	level = newLevel
+	logrus.SetLevel(newLevel)
+	for _, logger := range loggers {
+		logger.SetLevel(newLevel)
+	}
+	return nil
+}
+
+// Level returns the name of the current level, which SetLevel accepts.
+func Level() string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return level.String()
+}
+
 // 2015-06-10 20:10:08.123456
 func getTime() string {
 	var buf [30]byte
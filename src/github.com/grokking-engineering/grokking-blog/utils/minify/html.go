@@ -0,0 +1,146 @@
+package minify
+
+import "bytes"
+
+// Content of these elements is copied as is.
+var rawElements = []string{"pre", "textarea", "script", "style"}
+
+// HTML removes comments and collapses whitespace in text and tags. Content
+// of <pre>, <textarea>, <script> and <style> is kept as is, and so are
+// conditional comments.
+func HTML(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case bytes.HasPrefix(src[i:], []byte("<!--")):
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + 7
+			}
+			if bytes.HasPrefix(src[i:], []byte("<!--[if")) {
+				out = append(out, src[i:end]...)
+			}
+			i = end
+
+		case c == '<' && i+1 < len(src) && (isLetter(src[i+1]) || src[i+1] == '/' || src[i+1] == '!'):
+			end := tagEnd(src, i)
+			out = appendTag(out, src[i:end])
+			name := tagName(src[i:end])
+			i = end
+			for _, raw := range rawElements {
+				if name == raw {
+					closing := indexFold(src[i:], "</"+raw)
+					if closing < 0 {
+						closing = len(src) - i
+					}
+					out = append(out, src[i:i+closing]...)
+					i += closing
+				}
+			}
+
+		case isSpace(c):
+			j := i
+			newline := false
+			for j < len(src) && isSpace(src[j]) {
+				newline = newline || src[j] == '\n'
+				j++
+			}
+			if len(out) > 0 && j < len(src) {
+				if newline {
+					out = append(out, '\n')
+				} else {
+					out = append(out, ' ')
+				}
+			}
+			i = j
+
+		default:
+			out = append(out, c)
+			i++
+		}
+	}
+	return out
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// tagEnd returns the index after the tag starting at i.
+func tagEnd(src []byte, i int) int {
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '"', '\'':
+			j = attrQuoteEnd(src, j) - 1
+		case '>':
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// attrQuoteEnd returns the index after the closing quote of the attribute
+// value starting at src[i]. Unlike in CSS and JS, a backslash is not an
+// escape in HTML.
+func attrQuoteEnd(src []byte, i int) int {
+	if j := bytes.IndexByte(src[i+1:], src[i]); j >= 0 {
+		return i + j + 2
+	}
+	return len(src)
+}
+
+// tagName returns the lower case name of an opening tag, or "".
+func tagName(tag []byte) string {
+	j := 1
+	for j < len(tag) && (isLetter(tag[j]) || tag[j] >= '0' && tag[j] <= '9') {
+		j++
+	}
+	return string(bytes.ToLower(tag[1:j]))
+}
+
+// appendTag collapses whitespace between attributes, quoted values are kept
+// as is.
+func appendTag(out, tag []byte) []byte {
+	space := false
+	for j := 0; j < len(tag); j++ {
+		c := tag[j]
+		switch {
+		case isSpace(c):
+			space = true
+		case c == '"' || c == '\'':
+			if space && out[len(out)-1] != '=' {
+				out = append(out, ' ')
+				space = false
+			}
+			end := attrQuoteEnd(tag, j)
+			out = append(out, tag[j:end]...)
+			j = end - 1
+		default:
+			if space && c != '>' && !(c == '/' && j+1 < len(tag) && tag[j+1] == '>') && c != '=' && out[len(out)-1] != '=' {
+				out = append(out, ' ')
+			}
+			space = false
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// indexFold returns the index of the first case insensitive match of
+// s in src, or -1.
+func indexFold(src []byte, s string) int {
+	for i := 0; i+len(s) <= len(src); i++ {
+		j := bytes.IndexByte(src[i:], s[0])
+		if j < 0 || i+j+len(s) > len(src) {
+			return -1
+		}
+		i += j
+		if bytes.EqualFold(src[i:i+len(s)], []byte(s)) {
+			return i
+		}
+	}
+	return -1
+}
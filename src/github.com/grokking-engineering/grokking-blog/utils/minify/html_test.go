@@ -0,0 +1,28 @@
+package minify
+
+import "testing"
+
+func TestHTML(T *testing.T) {
+	tests := []struct {
+		src, expected string
+	}{
+		{"<html>\n  <head>\n    <title>Hi</title>\n  </head>\n</html>\n", "<html>\n<head>\n<title>Hi</title>\n</head>\n</html>"},
+		{"<p>Hello   <b>world</b>  !</p>", "<p>Hello <b>world</b> !</p>"},
+		{"<p>a<!-- comment -->b</p>", "<p>ab</p>"},
+		{"<!--[if IE]><p>IE</p><![endif]-->", "<!--[if IE]><p>IE</p><![endif]-->"},
+		{`<a   href="/a  b"   class = "x" >x</a>`, `<a href="/a  b" class="x">x</a>`},
+		{"<br />", "<br/>"},
+		{"<pre><code>a   b\n\n  c</code></pre>\n\n<p>x</p>", "<pre><code>a   b\n\n  c</code></pre>\n<p>x</p>"},
+		{"<PRE>a  <!-- b --></PRE>", "<PRE>a  <!-- b --></PRE>"},
+		{"<script>\n  if (a < b) {}\n</script>", "<script>\n  if (a < b) {}\n</script>"},
+		{"<textarea>  x  </textarea>", "<textarea>  x  </textarea>"},
+		{"a < b  c", "a < b c"},
+		{`<img alt="C:\Users\"   src="a.png">  <p>x</p>`, `<img alt="C:\Users\" src="a.png"> <p>x</p>`},
+		{`<a title='\'  href="/">x</a>`, `<a title='\' href="/">x</a>`},
+	}
+	for _, test := range tests {
+		if actual := string(HTML([]byte(test.src))); actual != test.expected {
+			T.Errorf("%q: expect %q, got %q", test.src, test.expected, actual)
+		}
+	}
+}
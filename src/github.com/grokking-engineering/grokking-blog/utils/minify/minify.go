@@ -0,0 +1,173 @@
+// Package minify removes comments and whitespace from CSS and JS. It is
+// conservative: output is always equivalent, but not as small as the output
+// of dedicated tools.
+package minify
+
+import "bytes"
+
+// CSS removes comments, collapses whitespace and drops it around
+// punctuation where it is not significant.
+func CSS(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	space := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+			space = true
+
+		case isSpace(c):
+			space = true
+
+		default:
+			if space && len(out) > 0 &&
+				bytes.IndexByte([]byte("{};:,>("), out[len(out)-1]) < 0 &&
+				bytes.IndexByte([]byte("{};,>)!"), c) < 0 {
+				out = append(out, ' ')
+			}
+			space = false
+
+			if c == '"' || c == '\'' {
+				j := quoteEnd(src, i)
+				out = append(out, src[i:j]...)
+				i = j - 1
+				continue
+			}
+			if c == '}' && len(out) > 0 && out[len(out)-1] == ';' {
+				out = out[:len(out)-1]
+			}
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// JS removes comments and collapses whitespace. Line breaks are kept where
+// automatic semicolon insertion may depend on them.
+func JS(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	space, newline := false, false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			end := bytes.IndexByte(src[i:], '\n')
+			if end < 0 {
+				return bytes.TrimSpace(out)
+			}
+			i += end - 1
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return bytes.TrimSpace(out)
+			}
+			i += end + 3
+			space = true
+
+		case c == '\n' || c == '\r':
+			newline = true
+
+		case isSpace(c):
+			space = true
+
+		default:
+			if len(out) > 0 {
+				last := out[len(out)-1]
+				switch {
+				case newline && bytes.IndexByte([]byte("{;,(["), last) < 0 && c != '}':
+					out = append(out, '\n')
+				case (newline || space) && (isIdent(last) && isIdent(c) || last == c && (c == '+' || c == '-')):
+					out = append(out, ' ')
+				}
+			}
+			space, newline = false, false
+
+			switch {
+			case c == '"' || c == '\'' || c == '`':
+				j := quoteEnd(src, i)
+				out = append(out, src[i:j]...)
+				i = j - 1
+			case c == '/' && isRegexStart(out):
+				j := regexEnd(src, i)
+				out = append(out, src[i:j]...)
+				i = j - 1
+			default:
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isIdent(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '$' || c == '\\' || c >= 0x80
+}
+
+// quoteEnd returns the index after the string starting at i.
+func quoteEnd(src []byte, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// isRegexStart reports whether a "/" after out starts a regular expression
+// instead of a division.
+func isRegexStart(out []byte) bool {
+	if len(out) == 0 {
+		return true
+	}
+	last := out[len(out)-1]
+	if bytes.IndexByte([]byte("(,=:[!&|?{};+-*%<>~^\n"), last) >= 0 {
+		return true
+	}
+	for _, keyword := range []string{"return", "typeof", "case", "do", "else", "in", "of"} {
+		if bytes.HasSuffix(out, []byte(keyword)) {
+			n := len(out) - len(keyword)
+			if n == 0 || !isIdent(out[n-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// regexEnd returns the index after the regular expression starting at i,
+// flags are copied as identifiers.
+func regexEnd(src []byte, i int) int {
+	class := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if !class {
+				return j + 1
+			}
+		case '\n':
+			return j
+		}
+	}
+	return len(src)
+}
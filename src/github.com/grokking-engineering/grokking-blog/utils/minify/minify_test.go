@@ -0,0 +1,43 @@
+package minify
+
+import "testing"
+
+func TestCSS(T *testing.T) {
+	tests := []struct {
+		src, expected string
+	}{
+		{"a { color: red ; }", "a{color:red}"},
+		{"/* comment */\nbody {\n  margin: 0;\n  padding: 0 1px;\n}\n", "body{margin:0;padding:0 1px}"},
+		{"div :first-child, p > a { x: y }", "div :first-child,p>a{x:y}"},
+		{"@media screen and (max-width: 600px) { a { b: c } }", "@media screen and (max-width:600px){a{b:c}}"},
+		{"a { width: calc(100% - 10px); }", "a{width:calc(100% - 10px)}"},
+		{`a::after { content: " /* x */ ; " }`, `a::after{content:" /* x */ ; "}`},
+		{"a { color: red !important; }", "a{color:red!important}"},
+	}
+	for _, test := range tests {
+		if actual := string(CSS([]byte(test.src))); actual != test.expected {
+			T.Errorf("%q: expect %q, got %q", test.src, test.expected, actual)
+		}
+	}
+}
+
+func TestJS(T *testing.T) {
+	tests := []struct {
+		src, expected string
+	}{
+		{"var a = 1;  // comment\nvar b = 2;", "var a=1;var b=2;"},
+		{"/* header */\nfunction f(x) {\n  return x + 1\n}\n", "function f(x){return x+1}"},
+		{"a = b\n(c)", "a=b\n(c)"},
+		{"a = b + +c; d = e - -f", "a=b+ +c;d=e- -f"},
+		{`s = "a // b"; t = 'c /* d */'`, `s="a // b";t='c /* d */'`},
+		{"r = /[/]\\/+/g.test(s) // x", "r=/[/]\\/+/g.test(s)"},
+		{"x = a / b / c", "x=a/b/c"},
+		{"return /a b/.test(s)", "return/a b/.test(s)"},
+		{"t = `a  ${b}  c`", "t=`a  ${b}  c`"},
+	}
+	for _, test := range tests {
+		if actual := string(JS([]byte(test.src))); actual != test.expected {
+			T.Errorf("%q: expect %q, got %q", test.src, test.expected, actual)
+		}
+	}
+}